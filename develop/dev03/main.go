@@ -2,28 +2,200 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/hurstcain/tasks_l2/develop/dev03/sorter"
+	"log"
 	"os"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 func main() {
-	var sourceFile, resultFile string
+	// Ключи сортировки. Флаг -k может быть указан несколько раз.
+	var keys []sorter.Key
+	flag.Func("k", "Ключ сортировки POS1[,POS2], где POS - F[.C][OPTS], например -k2,2n -k1,1r", func(kValue string) error {
+		key, err := sorter.ParseKey(kValue)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	nFlag := flag.Bool("n", false, "Сортировка по числовому значению")
+	rFlag := flag.Bool("r", false, "Сортировка в обратном порядке")
+	uFlag := flag.Bool("u", false, "Не выводить повторяющиеся строки.")
+	mFlag := flag.Bool("M", false, "Сортировка по названию месяца")
+	hFlag := flag.Bool("h", false, "Сортировка по числовому значению с учетом суффиксов, например 2K или 1G")
+	bFlag := flag.Bool("b", false, "Игнорировать начальные пробелы")
+	cFlag := flag.Bool("c", false, "Проверить, отсортированы ли данные, и вывести первую строку, которая нарушает порядок")
+	CFlag := flag.Bool("C", false, "Проверить, отсортированы ли данные, ничего не выводя")
+	fFlag := flag.Bool("f", false, "Игнорировать регистр")
+	VFlag := flag.Bool("V", false, "Сортировка номеров версий")
+	gFlag := flag.Bool("g", false, "Сортировка по значению чисел с плавающей точкой")
+	countFlag := flag.Bool("count", false, "Выводить перед строкой количество строк с равными ключами, как uniq -c")
+	repeatedFlag := flag.Bool("repeated", false, "Выводить только строки, у которых есть повторы, как uniq -d")
+	uniquesFlag := flag.Bool("uniques", false, "Выводить только строки без повторов, как uniq -u")
+	parallelFlag := flag.Int("parallel", 1, "Количество потоков для сортировки")
+	sFlag := flag.Bool("s", false, "Стабильная сортировка: строки с равными ключами остаются в исходном порядке")
+	// Разделитель столбцов.
+	var separator rune
+	flag.Func("t", "Разделитель столбцов (один символ, по умолчанию пробел)", func(tValue string) error {
+		if utf8.RuneCountInString(tValue) != 1 {
+			return errors.New("разделитель должен состоять из одного символа")
+		}
+		separator, _ = utf8.DecodeRuneInString(tValue)
+		return nil
+	})
+	// Объем памяти для сортировки. Файлы, которые не помещаются в этот объем, сортируются по частям.
+	var bufferSize int
+	flag.Func("S", "Объем памяти для сортировки в байтах, можно с суффиксом K, M или G (по умолчанию 64M)", func(sValue string) error {
+		size, err := parseSize(sValue)
+		if err != nil {
+			return err
+		}
+		bufferSize = size
+		return nil
+	})
+	// Флаги со значением можно указывать слитно со значением, как в утилите sort: -k2,2n, -t: или -S64M.
+	flag.CommandLine.Parse(expandArgs(os.Args[1:]))
+
+	decimalPoint, thousandsSep := numberLocale()
+	opts := sorter.Options{
+		Keys:                keys,
+		Separator:           separator,
+		IgnoreLeadingBlanks: *bFlag,
+		Numeric:             *nFlag,
+		GeneralNumeric:      *gFlag,
+		HumanNumeric:        *hFlag,
+		Month:               *mFlag,
+		Version:             *VFlag,
+		DecimalPoint:        decimalPoint,
+		ThousandsSeparator:  thousandsSep,
+		FoldCase:            *fFlag,
+		Reverse:             *rFlag,
+		Stable:              *sFlag,
+		Unique:              *uFlag,
+		Count:               *countFlag,
+		Repeated:            *repeatedFlag,
+		Uniques:             *uniquesFlag,
+		BufferSize:          bufferSize,
+		Parallel:            *parallelFlag,
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Print("Название исходного файла: ")
-	sourceFile, _ = reader.ReadString('\n')
-	fmt.Print("Название файла, куда будет записан результат: ")
-	resultFile, _ = reader.ReadString('\n')
+	sourceFile, _ := reader.ReadString('\n')
 	sourceFile = strings.TrimSpace(sourceFile)
+
+	// При флагах -c и -C файл не сортируется, а только проверяется.
+	if *cFlag || *CFlag {
+		os.Exit(check(sourceFile, opts, *cFlag))
+	}
+
+	fmt.Print("Название файла, куда будет записан результат: ")
+	resultFile, _ := reader.ReadString('\n')
 	resultFile = strings.TrimSpace(resultFile)
 
-	kFlag := flag.Int("k", 0, "Номер колонки для сортировки")
-	nFlag := flag.Bool("n", false, "Сортировка по числовому значению")
-	rFlag := flag.Bool("r", false, "Сортировка в обратном порядке")
-	uFlag := flag.Bool("u", false, "Не выводить повторяющиеся строки.")
-	flag.Parse()
+	if err := sorter.SortFile(sourceFile, resultFile, opts); err != nil {
+		log.Fatalf("Не удалось отсортировать файл %s. Ошибка: %v\n", sourceFile, err)
+	}
+
+	log.Printf("Сортировка строк из файла %s завершена\n", sourceFile)
+	log.Printf("Результат записан в файл %s\n", resultFile)
+}
+
+// Проверяет, отсортирован ли файл source, и возвращает код завершения программы, как утилита sort:
+// 0 - файл отсортирован, 1 - порядок нарушен, 2 - произошла ошибка.
+// Если verbose равен true (флаг -c), то первая строка, которая нарушает порядок, выводится в stderr.
+func check(source string, opts sorter.Options, verbose bool) int {
+	f, err := os.Open(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось открыть файл %s. Ошибка: %v\n", source, err)
+		return 2
+	}
+	defer f.Close()
+
+	err = sorter.Check(f, opts)
+	var disorder *sorter.DisorderError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &disorder):
+		if verbose {
+			fmt.Fprintf(os.Stderr, "\n%s:%v\n", source, disorder)
+		}
+		return 1
+	default:
+		fmt.Fprintf(os.Stderr, "Не удалось проверить файл %s. Ошибка: %v\n", source, err)
+		return 2
+	}
+}
+
+// Возвращает десятичный разделитель и разделитель разрядов чисел для локали из переменных окружения
+// LC_ALL, LC_NUMERIC или LANG, как утилита sort. Для локалей C и POSIX, а также неизвестных локалей
+// используется точка без разделителя разрядов.
+func numberLocale() (decimalPoint, thousandsSep rune) {
+	var locale string
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if locale = os.Getenv(name); locale != "" {
+			break
+		}
+	}
+	// Язык локали, например ru из ru_RU.UTF-8.
+	if i := strings.IndexAny(locale, "_.@"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	switch locale {
+	case "en":
+		return '.', ','
+	case "ru", "uk", "be", "kk", "fr", "pl", "cs", "sk", "sv", "fi", "nb":
+		return ',', '\u00a0'
+	case "de", "it", "es", "pt", "nl", "da", "tr", "id":
+		return ',', '.'
+	default:
+		return '.', 0
+	}
+}
+
+// Разделяет аргументы вида -k2,2n на флаг и значение: -k 2,2n. Аргументы после "--" не изменяются.
+func expandArgs(args []string) []string {
+	expanded := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(expanded, args[i:]...)
+		}
+		if len(arg) > 2 && arg[0] == '-' && strings.IndexByte("ktS", arg[1]) >= 0 && arg[2] != '=' {
+			expanded = append(expanded, arg[:2], arg[2:])
+			continue
+		}
+		expanded = append(expanded, arg)
+	}
+	return expanded
+}
+
+// Преобразует размер вида 100, 512K, 64M или 1G в количество байт.
+func parseSize(s string) (int, error) {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	size, err := strconv.Atoi(s)
+	if err != nil || size <= 0 {
+		return 0, errors.New("некорректный объем памяти")
+	}
 
-	sorter.Sort(sourceFile, resultFile, *kFlag, *nFlag, *rFlag, *uFlag)
+	return size * multiplier, nil
 }
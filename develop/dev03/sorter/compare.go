@@ -0,0 +1,153 @@
+package sorter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Функция сравнения двух значений ключа сортировки. Возвращает отрицательное число, если a < b,
+// 0, если значения равны, и положительное число, если a > b.
+type compareFunc func(a, b string) int
+
+// Возвращает функцию сравнения значений ключа в соответствии с типом сортировки ключа k.
+// Числа читаются в формате f.
+func keyCompare(k Key, f numberFormat) compareFunc {
+	switch {
+	case k.Numeric:
+		return f.compareNumbers
+	case k.GeneralNumeric:
+		return f.compareGeneralNumbers
+	case k.HumanNumeric:
+		return f.compareHumanNumbers
+	case k.Month:
+		return compareMonths
+	case k.Version:
+		return compareVersions
+	case k.FoldCase:
+		return compareFold
+	default:
+		return strings.Compare
+	}
+}
+
+// Названия месяцев для флага -M.
+var months = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+
+// Сравнение названий месяцев (флаг -M): JAN < FEB < ... < DEC.
+// Учитываются первые три буквы значения без учета регистра и начальных пробелов.
+// Значения, которые не являются названием месяца, меньше всех месяцев.
+func compareMonths(a, b string) int {
+	return compareInts(month(a), month(b))
+}
+
+// Возвращает номер месяца от 1 до 12 или 0, если значение s не является названием месяца.
+func month(s string) int {
+	s = strings.TrimLeft(s, " \t")
+	if len(s) > 3 {
+		s = s[:3]
+	}
+	s = strings.ToUpper(s)
+
+	for i, m := range months {
+		if s == m {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Сравнение номеров версий (флаг -V), например 1.2 < 1.10 < 1.10a.
+// Значения разбиваются на нечисловые и числовые части: числовые части сравниваются как числа,
+// а нечисловые - посимвольно, при этом буквы меньше остальных символов, а символ ~ меньше всех,
+// даже конца значения, поэтому 1.0~rc1 < 1.0.
+func compareVersions(a, b string) int {
+	for a != "" || b != "" {
+		// Сравниваем нечисловые части.
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			oa, ob := versionOrder(a), versionOrder(b)
+			if oa != ob {
+				return compareInts(oa, ob)
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		// Сравниваем числовые части без начальных нулей.
+		a = strings.TrimLeft(a, "0")
+		b = strings.TrimLeft(b, "0")
+		na, nb := digitsPrefix(a), digitsPrefix(b)
+		if na != nb {
+			return compareInts(na, nb)
+		}
+		if c := strings.Compare(a[:na], b[:nb]); c != 0 {
+			return c
+		}
+		a, b = a[na:], b[nb:]
+	}
+
+	return 0
+}
+
+// Вес первого символа нечисловой части версии s при сравнении.
+func versionOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z':
+		return int(s[0])
+	default:
+		return int(s[0]) + 256
+	}
+}
+
+// Возвращает количество цифр в начале строки s.
+func digitsPrefix(s string) int {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	return n
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Сравнение строк без учета регистра (флаг -f): строчные буквы считаются заглавными.
+func compareFold(a, b string) int {
+	for a != "" && b != "" {
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		if c := compareInts(int(unicode.ToUpper(ra)), int(unicode.ToUpper(rb))); c != 0 {
+			return c
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+
+	return compareInts(len(a), len(b))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Сравнивает логические значения: false < true.
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
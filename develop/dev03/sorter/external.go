@@ -0,0 +1,250 @@
+package sorter
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Объем памяти по умолчанию, который могут занимать строки при сортировке (64 МиБ).
+const defaultBufferSize = 64 << 20
+
+// Примерный объем памяти, который занимает строка помимо своих байт:
+// заголовок строки в слайсе sorter.lines и слайс значений ключей строки.
+const lineOverhead = 40
+
+// Внешняя сортировка данных, объем которых может превышать объем памяти.
+// Строки из r читаются частями, пока их объем не превысит sorter.opts.BufferSize.
+// Каждая часть сортируется в памяти так же, как и все данные, и записывается во временный файл.
+// Затем отсортированные части сливаются в w (k-way merge).
+// Если все данные поместились в память, то временные файлы не создаются.
+func (s *sorter) sortStream(r io.Reader, w io.Writer) error {
+	// После завершения работы функции временные файлы удаляются.
+	defer s.removeChunks()
+
+	// Объем прочитанных и еще не записанных во временный файл строк.
+	size := 0
+	// Построчно читаем данные из r.
+	br := bufio.NewReader(r)
+	for {
+		line, err := readLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать данные: %w", err)
+		}
+		s.lines = append(s.lines, line)
+		size += len(line) + lineOverhead
+
+		if size >= s.opts.BufferSize {
+			s.sortLines()
+			if err := s.writeChunk(); err != nil {
+				return err
+			}
+			size = 0
+		}
+	}
+
+	// Данные поместились в память.
+	if len(s.chunks) == 0 {
+		s.sortLines()
+		if err := s.writeResult(w); err != nil {
+			return fmt.Errorf("не удалось записать результат: %w", err)
+		}
+		return nil
+	}
+
+	if len(s.lines) > 0 {
+		s.sortLines()
+		if err := s.writeChunk(); err != nil {
+			return err
+		}
+	}
+	return s.mergeChunks(w)
+}
+
+// Записывает отсортированные строки во временный файл и освобождает память, которую они занимали.
+func (s *sorter) writeChunk() error {
+	name, err := createChunk(s.opts.TempDir, s.writeLines)
+	if err != nil {
+		return err
+	}
+	s.chunks = append(s.chunks, name)
+
+	s.lines = make([]string, 0)
+	return nil
+}
+
+// Создает в директории dir временный файл, записывает в него данные функцией write и возвращает имя файла.
+// Если произошла ошибка, то файл удаляется.
+func createChunk(dir string, write func(w io.Writer) error) (string, error) {
+	f, err := os.CreateTemp(dir, "sorter-*")
+	if err != nil {
+		return "", fmt.Errorf("не удалось создать временный файл: %w", err)
+	}
+
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("не удалось записать временный файл: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+// Удаляет временные файлы.
+func (s *sorter) removeChunks() {
+	for _, name := range s.chunks {
+		os.Remove(name)
+	}
+	s.chunks = nil
+}
+
+// Строка отсортированной части данных при слиянии.
+type chunkLine struct {
+	line string
+	// Значения ключей сортировки строки.
+	keys []string
+	// Номер части данных, из которой прочитана строка.
+	chunk int
+}
+
+// Куча строк, по одной из каждой части данных. В вершине кучи находится строка,
+// которая должна быть записана в результат следующей.
+type chunkHeap struct {
+	lines []chunkLine
+	s     *sorter
+}
+
+func (h chunkHeap) Len() int { return len(h.lines) }
+func (h chunkHeap) Less(i, j int) bool {
+	a, b := h.lines[i], h.lines[j]
+	if c := h.s.compare(a.line, a.keys, b.line, b.keys); c != 0 {
+		return c < 0
+	}
+	// Из равных строк первой записывается строка из более ранней части данных,
+	// поэтому при флаге -s порядок равных строк сохраняется, а при флаге -u остается первая из них.
+	return a.chunk < b.chunk
+}
+func (h chunkHeap) Swap(i, j int)       { h.lines[i], h.lines[j] = h.lines[j], h.lines[i] }
+func (h *chunkHeap) Push(x interface{}) { h.lines = append(h.lines, x.(chunkLine)) }
+func (h *chunkHeap) Pop() interface{} {
+	last := h.lines[len(h.lines)-1]
+	h.lines = h.lines[:len(h.lines)-1]
+	return last
+}
+
+// Максимальное количество частей данных, которые сливаются за один раз, а значит, и одновременно
+// открытых временных файлов. Если частей больше, то они сливаются в несколько проходов.
+// Значение уменьшается в тестах.
+var maxMergeChunks = 16
+
+// Сливает отсортированные части данных и записывает результат в w.
+// Строки сравниваются той же функцией, что и при сортировке в памяти, а из равных строк первой
+// записывается строка из более ранней части данных, поэтому группы строк с равными ключами
+// при флаге -u и других объединяются так же, как при сортировке в памяти.
+// Если частей больше maxMergeChunks, то сначала они сливаются в более крупные части (см. mergePass).
+func (s *sorter) mergeChunks(out io.Writer) error {
+	for len(s.chunks) > maxMergeChunks {
+		if err := s.mergePass(); err != nil {
+			return err
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	g := newGroupWriter(w, s)
+	if err := s.mergeFiles(s.chunks, g.write); err != nil {
+		return err
+	}
+	g.flush()
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("не удалось записать результат: %w", err)
+	}
+	return nil
+}
+
+// Сливает каждые maxMergeChunks соседних частей данных в одну часть и удаляет слитые временные файлы.
+// Новые части следуют в том же порядке, что и исходные, поэтому порядок равных строк сохраняется.
+func (s *sorter) mergePass() error {
+	merged := make([]string, 0, len(s.chunks)/maxMergeChunks+1)
+	for start := 0; start < len(s.chunks); start += maxMergeChunks {
+		end := start + maxMergeChunks
+		if end > len(s.chunks) {
+			end = len(s.chunks)
+		}
+
+		name, err := createChunk(s.opts.TempDir, func(w io.Writer) error {
+			bw := bufio.NewWriter(w)
+			err := s.mergeFiles(s.chunks[start:end], func(line string, _ []string) {
+				bw.WriteString(line)
+				bw.WriteByte('\n')
+			})
+			if err != nil {
+				return err
+			}
+			return bw.Flush()
+		})
+		if err != nil {
+			for _, name := range merged {
+				os.Remove(name)
+			}
+			return err
+		}
+		merged = append(merged, name)
+	}
+
+	s.removeChunks()
+	s.chunks = merged
+	return nil
+}
+
+// Сливает отсортированные временные файлы names и передает строки в порядке сортировки функции write.
+func (s *sorter) mergeFiles(names []string, write func(line string, keys []string)) error {
+	h := &chunkHeap{s: s}
+
+	// Открываем отсортированные части данных и читаем из каждой первую строку.
+	readers := make([]*bufio.Reader, 0, len(names))
+	for i, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("не удалось открыть временный файл: %w", err)
+		}
+		defer f.Close()
+
+		readers = append(readers, bufio.NewReader(f))
+		if err := s.nextChunkLine(h, readers[i], i); err != nil {
+			return err
+		}
+	}
+
+	for h.Len() > 0 {
+		cl := heap.Pop(h).(chunkLine)
+		if err := s.nextChunkLine(h, readers[cl.chunk], cl.chunk); err != nil {
+			return err
+		}
+		write(cl.line, cl.keys)
+	}
+
+	return nil
+}
+
+// Читает следующую строку из части данных с номером chunk и добавляет ее в кучу h.
+func (s *sorter) nextChunkLine(h *chunkHeap, r *bufio.Reader, chunk int) error {
+	line, err := readLine(r)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать временный файл: %w", err)
+	}
+
+	heap.Push(h, chunkLine{line: line, keys: s.lineKeys(line), chunk: chunk})
+	return nil
+}
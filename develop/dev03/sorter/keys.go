@@ -0,0 +1,329 @@
+package sorter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Key - ключ сортировки, который задается флагом -k POS1[,POS2] так же, как в утилите sort.
+// Позиция POS имеет вид F[.C][OPTS]: F - номер поля, C - номер символа в поле, начиная с 1,
+// OPTS - модификаторы ключа (b, f, g, h, M, n, r, V).
+// Например, -k2,2n - числовая сортировка по второму полю, -k1.3,1.5r - обратная сортировка
+// по символам с третьего по пятый в первом поле.
+//
+// Если у ключа не указан ни один модификатор, то он использует модификаторы из Options
+// (флаги -b, -f, -g, -h, -M, -n, -r и -V).
+type Key struct {
+	// Номер поля, с которого начинается ключ, начиная с 1.
+	StartField int
+	// Номер символа в поле StartField, с которого начинается ключ, начиная с 1.
+	// Если значение равно 0, то ключ начинается с начала поля.
+	StartChar int
+	// Номер поля, которым заканчивается ключ. Если значение равно 0, то ключ заканчивается в конце строки.
+	EndField int
+	// Номер последнего символа ключа в поле EndField. Если значение равно 0, то ключ заканчивается в конце поля.
+	EndChar int
+
+	// Игнорировать пробелы и табуляции в начале полей при отсчете символов (модификатор b).
+	IgnoreLeadingBlanks bool
+	// Сортировка по числовому значению (модификатор n).
+	Numeric bool
+	// Сортировка по значению чисел с плавающей точкой (модификатор g).
+	GeneralNumeric bool
+	// Сортировка по значению чисел с суффиксами единиц измерения (модификатор h).
+	HumanNumeric bool
+	// Сортировка по названиям месяцев (модификатор M).
+	Month bool
+	// Сортировка номеров версий (модификатор V).
+	Version bool
+	// Игнорировать регистр (модификатор f).
+	FoldCase bool
+	// Сортировка по ключу в обратном порядке (модификатор r).
+	Reverse bool
+}
+
+// ParseKey - разбирает описание ключа сортировки в формате флага -k: POS1[,POS2], где POS - F[.C][OPTS].
+// Возвращает ошибку ErrInvalidColumn, если описание некорректно.
+func ParseKey(spec string) (Key, error) {
+	var k Key
+	invalid := fmt.Errorf("%w: %s", ErrInvalidColumn, spec)
+
+	start, end := spec, ""
+	hasEnd := false
+	if i := strings.IndexByte(spec, ','); i >= 0 {
+		start, end, hasEnd = spec[:i], spec[i+1:], true
+	}
+
+	field, char, hasChar, mods, ok := parsePosition(start)
+	// Номер символа в начальной позиции не может быть равен 0.
+	if !ok || field < 1 || (hasChar && char < 1) {
+		return Key{}, invalid
+	}
+	k.StartField, k.StartChar = field, char
+	if !k.setModifiers(mods) {
+		return Key{}, invalid
+	}
+
+	if hasEnd {
+		field, char, _, mods, ok = parsePosition(end)
+		if !ok || field < 1 {
+			return Key{}, invalid
+		}
+		k.EndField, k.EndChar = field, char
+		if !k.setModifiers(mods) {
+			return Key{}, invalid
+		}
+	}
+
+	return k, nil
+}
+
+// Разбирает позицию F[.C][OPTS]. Возвращает номер поля, номер символа, указан ли номер символа,
+// строку модификаторов и false, если позиция некорректна.
+func parsePosition(pos string) (field, char int, hasChar bool, mods string, ok bool) {
+	n := digitsPrefix(pos)
+	field, err := strconv.Atoi(pos[:n])
+	if err != nil {
+		return 0, 0, false, "", false
+	}
+	pos = pos[n:]
+
+	if strings.HasPrefix(pos, ".") {
+		pos = pos[1:]
+		n = digitsPrefix(pos)
+		char, err = strconv.Atoi(pos[:n])
+		if err != nil {
+			return 0, 0, false, "", false
+		}
+		pos, hasChar = pos[n:], true
+	}
+
+	return field, char, hasChar, pos, true
+}
+
+// Устанавливает модификаторы ключа из строки mods. Возвращает false, если модификатор неизвестен.
+func (k *Key) setModifiers(mods string) bool {
+	for _, m := range mods {
+		switch m {
+		case 'b':
+			k.IgnoreLeadingBlanks = true
+		case 'n':
+			k.Numeric = true
+		case 'g':
+			k.GeneralNumeric = true
+		case 'h':
+			k.HumanNumeric = true
+		case 'M':
+			k.Month = true
+		case 'V':
+			k.Version = true
+		case 'f':
+			k.FoldCase = true
+		case 'r':
+			k.Reverse = true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Проверяет корректность ключа.
+func (k Key) validate() error {
+	if k.StartField < 1 || k.StartChar < 0 || k.EndField < 0 || k.EndChar < 0 || (k.EndField == 0 && k.EndChar > 0) {
+		return ErrInvalidColumn
+	}
+	if countTrue(k.Numeric, k.GeneralNumeric, k.HumanNumeric, k.Month, k.Version) > 1 {
+		return ErrIncompatibleOptions
+	}
+	return nil
+}
+
+// Проверяет, указан ли у ключа хотя бы один модификатор.
+func (k Key) hasModifiers() bool {
+	return countTrue(k.IgnoreLeadingBlanks, k.Numeric, k.GeneralNumeric, k.HumanNumeric,
+		k.Month, k.Version, k.FoldCase, k.Reverse) > 0
+}
+
+// Возвращает значение ключа в строке line.
+// Если разделитель sep равен 0, то поля разделяются последовательностями пробелов и табуляций,
+// при этом пробелы перед полем относятся к полю, как в утилите sort.
+// Символы отсчитываются как символы UTF-8, а не как байты.
+func (k Key) value(line string, sep rune) string {
+	start := fieldStart(line, k.StartField, sep)
+	if k.IgnoreLeadingBlanks {
+		start = skipBlanks(line, start, len(line))
+	}
+	if k.StartChar > 1 {
+		start = skipRunes(line, start, k.StartChar-1, len(line))
+	}
+
+	end := len(line)
+	if k.EndField > 0 {
+		end = fieldStart(line, k.EndField, sep)
+		fieldEnd := fieldEnd(line, end, sep)
+		if k.EndChar == 0 {
+			end = fieldEnd
+		} else {
+			if k.IgnoreLeadingBlanks {
+				end = skipBlanks(line, end, fieldEnd)
+			}
+			end = skipRunes(line, end, k.EndChar, fieldEnd)
+		}
+	}
+
+	if end <= start {
+		return ""
+	}
+	return line[start:end]
+}
+
+// Возвращает индекс начала поля с номером n в строке line или длину строки, если в строке меньше n полей.
+func fieldStart(line string, n int, sep rune) int {
+	i := 0
+	for f := 1; f < n; f++ {
+		if sep != 0 {
+			idx := strings.IndexRune(line[i:], sep)
+			if idx < 0 {
+				return len(line)
+			}
+			i += idx + utf8.RuneLen(sep)
+			continue
+		}
+		i = skipBlanks(line, i, len(line))
+		for i < len(line) && !isBlank(line[i]) {
+			i++
+		}
+	}
+	return i
+}
+
+// Возвращает индекс конца поля, которое начинается с индекса start.
+func fieldEnd(line string, start int, sep rune) int {
+	if sep != 0 {
+		if idx := strings.IndexRune(line[start:], sep); idx >= 0 {
+			return start + idx
+		}
+		return len(line)
+	}
+
+	i := skipBlanks(line, start, len(line))
+	for i < len(line) && !isBlank(line[i]) {
+		i++
+	}
+	return i
+}
+
+// Пропускает пробелы и табуляции в строке line, начиная с индекса i, но не дальше индекса lim.
+func skipBlanks(line string, i, lim int) int {
+	for i < lim && isBlank(line[i]) {
+		i++
+	}
+	return i
+}
+
+// Пропускает n символов в строке line, начиная с индекса i, но не дальше индекса lim.
+func skipRunes(line string, i, n, lim int) int {
+	for ; n > 0 && i < lim; n-- {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+	}
+	if i > lim {
+		return lim
+	}
+	return i
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// Возвращает количество истинных значений.
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
+
+// Ключ сортировки, подготовленный для сравнения строк: функция, которая выделяет значение ключа
+// из строки, и функция сравнения значений. Ключи образуют цепочку: следующий ключ сравнивается,
+// только если значения предыдущих ключей равны.
+type sortKey struct {
+	// Выделяет значение ключа из строки.
+	extract func(line string) string
+	// Сравнивает значения ключа.
+	compare compareFunc
+	// Сравнивать значения в обратном порядке.
+	reverse bool
+}
+
+// Возвращает цепочку ключей сортировки в соответствии с opts.
+// Если ключи не указаны, то используется один ключ: столбец Options.Column или вся строка.
+func (opts Options) sortKeys() []sortKey {
+	if len(opts.Keys) == 0 {
+		k := opts.globalKey()
+		return []sortKey{{
+			extract: opts.columnValue,
+			compare: keyCompare(k, opts.numberFormat()),
+			reverse: k.Reverse,
+		}}
+	}
+
+	keys := make([]sortKey, 0, len(opts.Keys))
+	for _, k := range opts.Keys {
+		if !k.hasModifiers() {
+			global := opts.globalKey()
+			global.StartField, global.StartChar, global.EndField, global.EndChar = k.StartField, k.StartChar, k.EndField, k.EndChar
+			k = global
+		}
+		// Копия ключа для замыкания.
+		k := k
+		keys = append(keys, sortKey{
+			extract: func(line string) string { return k.value(line, opts.Separator) },
+			compare: keyCompare(k, opts.numberFormat()),
+			reverse: k.Reverse,
+		})
+	}
+	return keys
+}
+
+// Возвращает ключ, который сравнивает строки целиком с модификаторами из opts.
+func (opts Options) globalKey() Key {
+	return Key{
+		StartField:          1,
+		IgnoreLeadingBlanks: opts.IgnoreLeadingBlanks,
+		Numeric:             opts.Numeric,
+		GeneralNumeric:      opts.GeneralNumeric,
+		HumanNumeric:        opts.HumanNumeric,
+		Month:               opts.Month,
+		Version:             opts.Version,
+		FoldCase:            opts.FoldCase,
+		Reverse:             opts.Reverse,
+	}
+}
+
+// Возвращает значение ключа, если ключи Options.Keys не указаны.
+// Если указан столбец k, то ключом является слово в столбце k, иначе вся строка.
+// Слова разделяются символом Options.Separator (по умолчанию пробелом).
+// Если в строке нет столбца k, то считается, что столбец k в строке равен "".
+// При флаге -b из значения удаляются начальные пробелы и табуляции.
+func (opts Options) columnValue(line string) string {
+	if k := opts.Column; k > 0 {
+		sep := " "
+		if opts.Separator != 0 {
+			sep = string(opts.Separator)
+		}
+		line = field(line, k, sep)
+	}
+	if opts.IgnoreLeadingBlanks {
+		line = strings.TrimLeft(line, " \t")
+	}
+
+	return line
+}
@@ -0,0 +1,295 @@
+package sorter
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Формат записи чисел: десятичный разделитель и разделитель разрядов, которые зависят от локали.
+type numberFormat struct {
+	decimalPoint rune
+	// Если значение равно 0, то разряды не разделяются.
+	thousandsSep rune
+}
+
+// Возвращает формат записи чисел из opts. По умолчанию используется формат локали C:
+// десятичный разделитель - точка, разделитель разрядов отсутствует.
+func (opts Options) numberFormat() numberFormat {
+	f := numberFormat{decimalPoint: opts.DecimalPoint, thousandsSep: opts.ThousandsSeparator}
+	if f.decimalPoint == 0 {
+		f.decimalPoint = '.'
+	}
+	return f
+}
+
+// Десятичное число, прочитанное из строки. Нулевое значение структуры равно нулю.
+type decimal struct {
+	negative bool
+	// Цифры целой части без начальных нулей и разделителей разрядов.
+	intPart string
+	// Цифры дробной части без конечных нулей.
+	fracPart string
+}
+
+// Возвращает знак числа: -1, 0 или 1.
+func (d decimal) sign() int {
+	switch {
+	case d.intPart == "" && d.fracPart == "":
+		return 0
+	case d.negative:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// Сравнивает числа. Количество цифр не ограничено, поэтому числа любой длины сравниваются точно.
+func compareDecimals(a, b decimal) int {
+	signA, signB := a.sign(), b.sign()
+	if signA != signB || signA == 0 {
+		return compareInts(signA, signB)
+	}
+
+	// Сравниваем абсолютные значения: сначала количество цифр целой части, затем цифры.
+	c := compareInts(len(a.intPart), len(b.intPart))
+	if c == 0 {
+		c = strings.Compare(a.intPart, b.intPart)
+	}
+	if c == 0 {
+		c = strings.Compare(a.fracPart, b.fracPart)
+	}
+	return signA * c
+}
+
+// Читает число из начала строки s так же, как утилита sort при флаге -n:
+// начальные пробелы и табуляции пропускаются, затем идут необязательный знак минус, цифры целой части,
+// которые могут быть разделены разделителем разрядов, и дробная часть после десятичного разделителя.
+// Символы после числа игнорируются, поэтому 12abc равно 12.
+// Возвращает число, остаток строки после числа и false, если строка не начинается с числа.
+func (f numberFormat) parseNumber(s string) (decimal, string, bool) {
+	s = s[skipBlanks(s, 0, len(s)):]
+
+	var d decimal
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		d.negative = true
+		i++
+	}
+
+	intStart := i
+	hasSep := false
+	for i < len(s) {
+		if isDigit(s[i]) {
+			i++
+			continue
+		}
+		// Разделитель разрядов допустим только между цифрами.
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if f.thousandsSep == 0 || r != f.thousandsSep || i == intStart || i+size >= len(s) || !isDigit(s[i+size]) {
+			break
+		}
+		hasSep = true
+		i += size
+	}
+	d.intPart = s[intStart:i]
+	if hasSep {
+		d.intPart = strings.ReplaceAll(d.intPart, string(f.thousandsSep), "")
+	}
+
+	if r, size := utf8.DecodeRuneInString(s[i:]); r == f.decimalPoint {
+		fracStart := i + size
+		end := fracStart + digitsPrefix(s[fracStart:])
+		// Десятичный разделитель без цифр не является числом.
+		if end > fracStart || d.intPart != "" {
+			d.fracPart = s[fracStart:end]
+			i = end
+		}
+	}
+
+	if d.intPart == "" && d.fracPart == "" {
+		return decimal{}, s, false
+	}
+
+	d.intPart = strings.TrimLeft(d.intPart, "0")
+	d.fracPart = strings.TrimRight(d.fracPart, "0")
+	return d, s[i:], true
+}
+
+// Сравнение чисел (флаг -n).
+// Число читается из начала значения, а значения, которые не начинаются с числа, равны нулю,
+// поэтому они упорядочиваются между отрицательными и положительными числами сравнением строк целиком.
+// Пример сортировки:
+// 35				-4
+// -4				qq
+// 25				w wg
+// 1,5				ааа
+// 6		=>		1,5
+// ааа				1e3
+// qq				6
+// 65gg				25
+// w wg				35
+// 1e3				65gg
+// При сортировке в локали C число 1,5 равно 1, а 1e3 равно 1, поэтому эти строки сравниваются целиком.
+func (f numberFormat) compareNumbers(a, b string) int {
+	da, _, _ := f.parseNumber(a)
+	db, _, _ := f.parseNumber(b)
+	return compareDecimals(da, db)
+}
+
+// Суффиксы единиц измерения для флага -h в порядке возрастания.
+const humanSuffixes = "KMGTPEZYRQ"
+
+// Сравнение чисел с суффиксами единиц измерения, например 2K или 1G (флаг -h).
+// Сначала сравниваются знаки чисел, затем суффиксы, а при одинаковых суффиксах - сами числа,
+// поэтому 1G больше, чем 1023M. Значения, которые не начинаются с числа, равны нулю.
+func (f numberFormat) compareHumanNumbers(a, b string) int {
+	da, sa := f.parseHumanNumber(a)
+	db, sb := f.parseHumanNumber(b)
+
+	// Ноль без суффикса и с суффиксом равны.
+	signA, signB := da.sign(), db.sign()
+	if signA != signB || signA == 0 {
+		return compareInts(signA, signB)
+	}
+	// Для отрицательных чисел больший суффикс означает меньшее число.
+	if sa != sb {
+		return signA * compareInts(sa, sb)
+	}
+	return compareDecimals(da, db)
+}
+
+// Читает из начала строки s число и суффикс единицы измерения.
+// Возвращает число и номер суффикса (0 - без суффикса, 1 - K, 2 - M и т.д.).
+func (f numberFormat) parseHumanNumber(s string) (decimal, int) {
+	d, rest, ok := f.parseNumber(s)
+	if !ok || rest == "" {
+		return d, 0
+	}
+
+	c := rest[0]
+	if c == 'k' {
+		c = 'K'
+	}
+	return d, strings.IndexByte(humanSuffixes, c) + 1
+}
+
+// Сравнение чисел с плавающей точкой, в том числе в экспоненциальной записи (флаг -g).
+// Число читается из начала значения. Порядок значений: значения, которые не начинаются с числа,
+// NaN, минус бесконечность, конечные числа по возрастанию, плюс бесконечность.
+// Точность не ограничена: числа, которые равны после преобразования в float64,
+// сравниваются как big.Float с точностью, достаточной для всех цифр числа.
+func (f numberFormat) compareGeneralNumbers(a, b string) int {
+	na, oka := f.floatPrefix(a)
+	nb, okb := f.floatPrefix(b)
+	if oka != okb || !oka {
+		return compareBools(oka, okb)
+	}
+
+	nanA, nanB := isNaN(na), isNaN(nb)
+	if nanA || nanB {
+		return compareBools(!nanA, !nanB)
+	}
+
+	// Округление при преобразовании в float64 не меняет порядок чисел, поэтому разные значения
+	// float64 упорядочены так же, как исходные числа. Ошибка выхода за пределы диапазона не важна:
+	// в этом случае возвращается бесконечность или 0 с нужным знаком.
+	fa, _ := strconv.ParseFloat(na, 64)
+	fb, _ := strconv.ParseFloat(nb, 64)
+	if fa != fb {
+		if fa < fb {
+			return -1
+		}
+		return 1
+	}
+	if na == nb || exactFloat(na, fa) && exactFloat(nb, fb) {
+		return 0
+	}
+
+	return bigFloat(na, fa).Cmp(bigFloat(nb, fb))
+}
+
+// Возвращает самое длинное число с плавающей точкой в начале строки s, пропуская начальные пробелы,
+// в формате strconv.ParseFloat: с точкой в качестве десятичного разделителя.
+// Число может быть десятичным, в экспоненциальной записи, а также inf, infinity или nan с любым регистром.
+// Возвращает false, если строка не начинается с числа.
+func (f numberFormat) floatPrefix(s string) (string, bool) {
+	s = s[skipBlanks(s, 0, len(s)):]
+
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	for _, word := range []string{"infinity", "inf", "nan"} {
+		if len(s)-i >= len(word) && strings.EqualFold(s[i:i+len(word)], word) {
+			return s[:i+len(word)], true
+		}
+	}
+
+	digits := digitsPrefix(s[i:])
+	i += digits
+	if r, size := utf8.DecodeRuneInString(s[i:]); r == f.decimalPoint {
+		if frac := digitsPrefix(s[i+size:]); frac > 0 || digits > 0 {
+			digits += frac
+			i += size + frac
+		}
+	}
+	if digits == 0 {
+		return "", false
+	}
+
+	// Экспонента учитывается, только если после e есть цифры.
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '-' || s[j] == '+') {
+			j++
+		}
+		if n := digitsPrefix(s[j:]); n > 0 {
+			i = j + n
+		}
+	}
+
+	number := s[:i]
+	if f.decimalPoint != '.' {
+		number = strings.Replace(number, string(f.decimalPoint), ".", 1)
+	}
+	return number, true
+}
+
+// Проверяет, однозначно ли число s определяется своим значением f типа float64: это так для нормализованных
+// чисел, в записи которых не больше 15 цифр. Тогда числа с равными значениями float64 равны.
+func exactFloat(s string, f float64) bool {
+	if math.IsInf(f, 0) || math.Abs(f) < 0x1p-1022 {
+		return false
+	}
+
+	digits := 0
+	for i := 0; i < len(s) && s[i] != 'e' && s[i] != 'E'; i++ {
+		if isDigit(s[i]) {
+			digits++
+		}
+	}
+	return digits <= 15
+}
+
+// Проверяет, является ли число, которое вернул floatPrefix, значением NaN.
+func isNaN(number string) bool {
+	if number != "" && (number[0] == '-' || number[0] == '+') {
+		number = number[1:]
+	}
+	return len(number) == 3 && strings.EqualFold(number, "nan")
+}
+
+// Преобразует число s в big.Float с точностью, достаточной для всех цифр числа.
+// Если число невозможно преобразовать (например, экспонента слишком велика),
+// то используется его значение f типа float64.
+func bigFloat(s string, f float64) *big.Float {
+	prec := uint(len(s))*4 + 64
+	x, _, err := big.ParseFloat(s, 10, prec, big.ToNearestEven)
+	if err != nil {
+		return big.NewFloat(f)
+	}
+	return x
+}
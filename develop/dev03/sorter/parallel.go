@@ -0,0 +1,108 @@
+package sorter
+
+import (
+	"sort"
+	"sync"
+)
+
+// Минимальное количество строк в одной части данных при параллельной сортировке.
+// Меньшие объемы данных быстрее отсортировать в одном потоке. Значение уменьшается в тестах.
+var minParallelLines = 1 << 14
+
+// Возвращает количество частей, на которые делятся n строк для параллельной сортировки (флаг --parallel).
+func (s *sorter) partsCount(n int) int {
+	parts := s.opts.Parallel
+	if maxParts := n / minParallelLines; parts > maxParts {
+		parts = maxParts
+	}
+	if parts < 1 {
+		return 1
+	}
+	return parts
+}
+
+// Делит n строк на parts последовательных частей и вызывает f для каждой части [lo, hi)
+// в отдельной горутине. Возвращается после завершения всех вызовов.
+func forEachPart(n, parts int, f func(lo, hi int)) {
+	if parts == 1 {
+		f(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < parts; i++ {
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			f(lo, hi)
+		}(n*i/parts, n*(i+1)/parts)
+	}
+	wg.Wait()
+}
+
+// Сортирует строки ls, разделив их на parts частей: части сортируются параллельно,
+// а затем попарно сливаются, пока не останется одна часть. Слияния одного шага тоже выполняются параллельно.
+// Части следуют в порядке входных данных, а при слиянии из равных строк первой берется строка
+// из более ранней части, поэтому при флаге -s порядок равных строк сохраняется.
+// Возвращает отсортированные строки и значения их ключей.
+func (s *sorter) parallelSort(ls keyedLines, parts int) ([]string, [][]string) {
+	n := ls.Len()
+	// Границы отсортированных частей: часть i - это строки [bounds[i], bounds[i+1]).
+	bounds := make([]int, 0, parts+1)
+	for i := 0; i <= parts; i++ {
+		bounds = append(bounds, n*i/parts)
+	}
+
+	forEachPart(n, parts, func(lo, hi int) {
+		part := keyedLines{lines: ls.lines[lo:hi], keys: ls.keys[lo:hi], s: s}
+		if s.opts.Stable {
+			sort.Stable(part)
+		} else {
+			sort.Sort(part)
+		}
+	})
+
+	// Слияние выполняется из src в dst, после каждого шага слайсы меняются местами.
+	src := ls
+	dst := keyedLines{lines: make([]string, n), keys: make([][]string, n), s: s}
+	for len(bounds) > 2 {
+		merged := make([]int, 0, len(bounds)/2+1)
+		var wg sync.WaitGroup
+		for i := 0; i+1 < len(bounds); i += 2 {
+			merged = append(merged, bounds[i])
+			// Нечетная последняя часть переносится без слияния.
+			if i+2 >= len(bounds) {
+				copy(dst.lines[bounds[i]:], src.lines[bounds[i]:bounds[i+1]])
+				copy(dst.keys[bounds[i]:], src.keys[bounds[i]:bounds[i+1]])
+				continue
+			}
+
+			wg.Add(1)
+			go func(lo, mid, hi int) {
+				defer wg.Done()
+				s.merge(dst, src, lo, mid, hi)
+			}(bounds[i], bounds[i+1], bounds[i+2])
+		}
+		wg.Wait()
+
+		bounds = append(merged, n)
+		src, dst = dst, src
+	}
+
+	return src.lines, src.keys
+}
+
+// Сливает отсортированные строки src[lo:mid] и src[mid:hi] в dst[lo:hi].
+// Из равных строк первой записывается строка из src[lo:mid].
+func (s *sorter) merge(dst, src keyedLines, lo, mid, hi int) {
+	i, j := lo, mid
+	for k := lo; k < hi; k++ {
+		if j >= hi || (i < mid && !src.Less(j, i)) {
+			dst.lines[k], dst.keys[k] = src.lines[i], src.keys[i]
+			i++
+		} else {
+			dst.lines[k], dst.keys[k] = src.lines[j], src.keys[j]
+			j++
+		}
+	}
+}
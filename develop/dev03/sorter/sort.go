@@ -1,9 +1,199 @@
+// Package sorter реализует сортировку строк так же, как это делает утилита sort:
+// обычная сортировка и сортировка по столбцу, сортировка чисел, размеров, месяцев и версий,
+// обратная и стабильная сортировка, вывод уникальных строк и проверка того, что строки отсортированы.
+// Данные, которые не помещаются в память, сортируются по частям с помощью временных файлов.
+//
+// Пример использования:
+//
+//	key, err := sorter.ParseKey("2,2n")
+//	if err != nil {
+//		return err
+//	}
+//	err = sorter.SortReader(os.Stdin, os.Stdout, sorter.Options{Keys: []sorter.Key{key}})
+//	if err != nil {
+//		return err
+//	}
 package sorter
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"os"
 )
 
+// Ошибки в параметрах сортировки.
+var (
+	// ErrInvalidColumn - некорректный номер столбца для сортировки (флаг -k).
+	ErrInvalidColumn = errors.New("некорректное значение флага k")
+	// ErrIncompatibleOptions - указано несколько типов сортировки одновременно.
+	ErrIncompatibleOptions = errors.New("флаги -n, -g, -h, -M и -V не могут быть указаны одновременно")
+	// ErrInvalidParallel - некорректное количество потоков для сортировки (флаг --parallel).
+	ErrInvalidParallel = errors.New("некорректное значение флага parallel")
+	// ErrIncompatibleGroups - указаны одновременно флаги --repeated и --uniques.
+	ErrIncompatibleGroups = errors.New("флаги --repeated и --uniques не могут быть указаны одновременно")
+)
+
+// Options - параметры сортировки. Нулевое значение полей соответствует поведению утилиты sort без флагов.
+type Options struct {
+	// Ключи сортировки (флаги -k). Строки сравниваются по первому ключу, при равенстве - по второму и т.д.
+	// Если ключи не указаны, то используется Column.
+	Keys []Key
+	// Номер столбца для сортировки, начиная с 1: ключом является одно слово между разделителями.
+	// Если значение равно 0, то строки сортируются целиком. Не может быть указан вместе с Keys.
+	Column int
+	// Разделитель столбцов (флаг -t). Если значение равно 0, то столбцы Column разделяются пробелом,
+	// а поля ключей Keys - последовательностями пробелов и табуляций.
+	Separator rune
+	// Игнорировать пробелы и табуляции в начале ключа сортировки (флаг -b).
+	// Этот и следующие модификаторы используются для ключей Keys, у которых не указаны свои модификаторы.
+	IgnoreLeadingBlanks bool
+
+	// Тип сортировки. Одновременно может быть указан только один из типов.
+	// Если ни один тип не указан, то ключи сравниваются как строки.
+
+	// Сортировка по числовому значению (флаг -n).
+	Numeric bool
+	// Сортировка по значению чисел с плавающей точкой (флаг -g).
+	GeneralNumeric bool
+	// Сортировка по значению чисел с суффиксами единиц измерения, например 2K или 1G (флаг -h).
+	HumanNumeric bool
+	// Сортировка по названиям месяцев (флаг -M).
+	Month bool
+	// Сортировка номеров версий (флаг -V).
+	Version bool
+
+	// Десятичный разделитель и разделитель разрядов чисел при флагах -n, -g и -h, которые в утилите sort
+	// зависят от локали. Если DecimalPoint равен 0, то используется точка.
+	// Если ThousandsSeparator равен 0, то разряды не разделяются.
+	DecimalPoint       rune
+	ThousandsSeparator rune
+
+	// Игнорировать регистр при сравнении строк (флаг -f).
+	FoldCase bool
+	// Сортировка в обратном порядке (флаг -r).
+	Reverse bool
+	// Стабильная сортировка (флаг -s): строки с равными ключами не сравниваются целиком,
+	// а остаются в порядке входных данных.
+	Stable bool
+	// Не выводить повторяющиеся строки (флаг -u): из строк с равными ключами остается первая
+	// во входных данных. Строки с равными ключами не сравниваются целиком, как при флаге -s.
+	// При сортировке по столбцу повторяющимися считаются строки с равным значением столбца.
+	Unique bool
+	// Выводить перед строкой количество строк с равными ключами, как uniq -c (флаг --count).
+	// Этот и следующие флаги, как и Unique, выводят одну строку из группы строк с равными ключами.
+	Count bool
+	// Выводить только строки, у которых есть повторы, как uniq -d (флаг --repeated).
+	Repeated bool
+	// Выводить только строки без повторов, как uniq -u (флаг --uniques).
+	Uniques bool
+	// Примерный объем памяти в байтах, который могут занимать строки (флаг -S).
+	// Данные большего объема сортируются по частям с помощью временных файлов.
+	// Если значение не положительное, то используется объем по умолчанию (64 МиБ).
+	BufferSize int
+	// Директория для временных файлов. Если значение пустое, то используется os.TempDir.
+	TempDir string
+	// Количество потоков для сортировки (флаг --parallel). Строки делятся на части, которые сортируются
+	// параллельно и затем сливаются. Если значение не больше 1, то строки сортируются в одном потоке.
+	Parallel int
+}
+
+// SortReader - сортирует строки из r и записывает результат в w.
+// Возвращает ошибку, если параметры opts некорректны или произошла ошибка чтения, записи
+// или работы с временными файлами. Временные файлы удаляются в любом случае.
+func SortReader(r io.Reader, w io.Writer, opts Options) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+
+	// Экземпляр структуры sorter.
+	s := newSorter(opts)
+	return s.sortStream(r, w)
+}
+
+// Проверяет корректность параметров сортировки.
+func (opts Options) validate() error {
+	if opts.Column < 0 || (opts.Column > 0 && len(opts.Keys) > 0) {
+		return ErrInvalidColumn
+	}
+	for _, k := range opts.Keys {
+		if err := k.validate(); err != nil {
+			return err
+		}
+	}
+
+	if countTrue(opts.Numeric, opts.GeneralNumeric, opts.HumanNumeric, opts.Month, opts.Version) > 1 {
+		return ErrIncompatibleOptions
+	}
+	if opts.Repeated && opts.Uniques {
+		return ErrIncompatibleGroups
+	}
+	if opts.Parallel < 0 {
+		return ErrInvalidParallel
+	}
+
+	return nil
+}
+
+// Проверяет, объединяются ли строки с равными ключами в группы при выводе.
+func (opts Options) grouped() bool {
+	return opts.Unique || opts.Count || opts.Repeated || opts.Uniques
+}
+
+// DisorderError - ошибка, которую возвращает Check, если строки не отсортированы.
+type DisorderError struct {
+	// Номер первой строки, которая нарушает порядок, начиная с 1.
+	Line int
+	// Текст этой строки.
+	Text string
+}
+
+func (e *DisorderError) Error() string {
+	return fmt.Sprintf("%d: нарушен порядок: %s", e.Line, e.Text)
+}
+
+// Check - проверяет, отсортированы ли строки из r в соответствии с opts (флаги -c и -C).
+// Возвращает *DisorderError с первой строкой, которая нарушает порядок, если строки не отсортированы.
+// При Options.Unique строки с равными ключами также нарушают порядок.
+// Строки читаются по одной, поэтому объем данных не ограничен объемом памяти.
+func Check(r io.Reader, opts Options) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	// При проверке уникальности строки с равными ключами не сравниваются целиком.
+	s := newSorter(opts)
+	var prev string
+	var prevKeys []string
+	lineNum := 0
+	br := bufio.NewReader(r)
+	for {
+		line, err := readLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать данные: %w", err)
+		}
+		keys := s.lineKeys(line)
+		lineNum++
+
+		if lineNum > 1 {
+			c := s.compare(prev, prevKeys, line, keys)
+			if c > 0 || (c == 0 && opts.Unique) {
+				return &DisorderError{Line: lineNum, Text: line}
+			}
+		}
+		prev, prevKeys = line, keys
+	}
+
+	return nil
+}
+
 // Sort - функция сортировки файла.
 // source - исходный файл.
 // result - файл, в который записывается результат.
@@ -11,33 +201,47 @@ import (
 // n - сортировка чисел.
 // r - обратная сортировка.
 // u - вывод уникальных строк.
+// При ошибке программа завершается. Остальные параметры сортировки, в том числе объем памяти,
+// задаются в Options функции SortFile, которая возвращает ошибку.
 func Sort(source, result string, k int, n, r, u bool) {
-	// Экземпляр структуры sorter.
-	s := newSorter(source, result)
-	s.readFile()
-
-	switch {
-	case k > 0:
-		// Сортировка по столбцам.
-		s.createMatrix()
-		if u {
-			s.createSet(true, k)
-		}
-		s.sortByColumn(k, r, n)
+	err := SortFile(source, result, Options{
+		Column:  k,
+		Numeric: n,
+		Reverse: r,
+		Unique:  u,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	case k == 0:
-		// Обычная сортировка.
-		if u {
-			s.createSet(false, 0)
-		}
-		s.simpleSort(r, n)
+	log.Printf("Сортировка строк из файла %s завершена\n", source)
+	log.Printf("Результат записан в файл %s\n", result)
+}
 
-	default:
-		log.Fatalln("Некорректное значение флага k")
+// SortFile - сортирует строки файла source с параметрами opts и записывает результат в файл result.
+func SortFile(source, result string, opts Options) error {
+	if err := opts.validate(); err != nil {
+		return err
 	}
 
-	s.createResultFile(k)
+	// Открываем исходный файл.
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть исходный файл: %w", err)
+	}
+	// После завершения работы функции файл закрывается.
+	defer in.Close()
 
-	log.Printf("Сортировка строк из файла %s завершена\n", source)
-	log.Printf("Результат записан в файл %s\n", result)
+	// Создаем файл результат.
+	out, err := os.Create(result)
+	if err != nil {
+		return fmt.Errorf("не удалось создать файл: %w", err)
+	}
+
+	err = SortReader(in, out, opts)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("не удалось записать результат в файл: %w", closeErr)
+	}
+
+	return err
 }
@@ -2,423 +2,250 @@ package sorter
 
 import (
 	"bufio"
-	"log"
-	"os"
+	"fmt"
+	"io"
 	"sort"
-	"strconv"
 	"strings"
 )
 
-// Структура содержит различные методы для сортировки файлов.
+// Структура содержит различные методы для сортировки строк.
 // Для сортировки используются функции из пакета sort.
 type sorter struct {
-	// Слайс строк файла.
-	// Используется для обычной сортировки (не по столбцам).
+	// Слайс строк.
 	lines []string
-	// Двумерный слайс, в котором содержатся слова из каждой строки.
-	// Используется для сортировки по столбцам.
-	matrixLines [][]string
-	// Название исходного файла.
-	sourceFileName string
-	// Название файла, в который будет записан результат.
-	resultFileName string
+	// Значения ключей строк sorter.lines после сортировки.
+	values [][]string
+	// Параметры сортировки.
+	opts Options
+	// Цепочка ключей сортировки.
+	keys []sortKey
+	// Названия временных файлов с отсортированными частями исходных данных.
+	chunks []string
 }
 
 // Конструктор структуры sorter.
-func newSorter(sourceFileName, resultFileName string) sorter {
+// При выводе групп строк с равными ключами (флаг -u и другие) строки с равными ключами не сравниваются
+// целиком, как при флаге -s, поэтому в группе первой остается строка, которая раньше во входных данных.
+func newSorter(opts Options) sorter {
+	if opts.grouped() {
+		opts.Stable = true
+	}
 	return sorter{
-		lines:          make([]string, 0),
-		matrixLines:    make([][]string, 0),
-		sourceFileName: sourceFileName,
-		resultFileName: resultFileName,
+		lines: make([]string, 0),
+		opts:  opts,
+		keys:  opts.sortKeys(),
 	}
 }
 
-// Производит чтение данных из исходного файла.
-func (s *sorter) readFile() {
-	// Открываем файл f.
-	f, err := os.Open(s.sourceFileName)
-	if err != nil {
-		log.Fatalf("Не удалось открыть исходный файл. Ошибка: %v\n", err)
-	}
-	// После завершения работы функции файл закрывается.
-	defer f.Close()
-
-	// Построчно читаем данные из файла f.
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		s.lines = append(s.lines, scanner.Text())
+// Производит чтение всех строк из r.
+func (s *sorter) readLines(r io.Reader) error {
+	// Построчно читаем данные из r.
+	br := bufio.NewReader(r)
+	for {
+		line, err := readLine(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.lines = append(s.lines, line)
 	}
 }
 
-// Записывает отсортированные данные в файл результат.
-// Если программа запускается со значением флага k > 0, то данные в файл записываются
-// из двумерного слайса sorter.matrixLines.
-func (s sorter) createResultFile(k int) {
-	// Создаем файл результат f.
-	f, err := os.Create(s.resultFileName)
+// Читает из r следующую строку без символа конца строки. Длина строки не ограничена.
+// Как и bufio.ScanLines, удаляет символ \r перед \n и возвращает последнюю строку без \n.
+// Возвращает io.EOF, если строки закончились.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
 	if err != nil {
-		log.Fatalf("Не удалось создать файл. Ошибка: %v\n", err)
+		return "", err
 	}
-	// После завершения работы функции файл закрывается.
-	defer f.Close()
 
-	// Запись отсортированных данных в файл.
-	if k > 0 {
-		for _, str := range s.matrixLines {
-			f.WriteString(strings.Join(str, " ") + "\n")
-		}
-	} else {
-		for _, str := range s.lines {
-			f.WriteString(str + "\n")
-		}
-	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
 }
 
-// Обычная сортировка. Данный метод используется тогда, когда программа запускается без флага k.
-// В функции реализованы также обратная сортировка, сортировка чисел и их комбинации.
-// reverse - флаг обратной сортировки, num - флаг сортировки чисел.
-func (s *sorter) simpleSort(reverse bool, num bool) {
-	switch {
-	case reverse:
-		switch {
-		case num:
-			// Обратная сортировка чисел.
-			// Работает по такому же принципу, что и обычная сортировка чисел,
-			// но только данные сортируются в обратном порядке.
-			// Пример сортировки:
-			// 35				899
-			// 46				46
-			// 25				35
-			// 899				25
-			// 6		=>		6
-			// ааа				вв
-			// вв				ааа
-			// qq				w wg
-			// 65gg				qq
-			// w wg				65gg
-			sort.Slice(s.lines, func(i, j int) bool {
-				si := s.lines[i]
-				sj := s.lines[j]
-
-				di, oki := toNumber(si)
-				dj, okj := toNumber(sj)
+// Записывает отсортированные строки в w без объединения в группы (во временный файл).
+func (s sorter) writeLines(w io.Writer) error {
+	bw := bufio.NewWriter(w)
 
-				if oki && okj {
-					return di > dj
-				}
-				if oki && !okj {
-					return true
-				}
-				if !oki && okj {
-					return false
-				}
-				return si > sj
-			})
-
-		default:
-			// Обратная сортировка строк.
-			// Пример сортировки:
-			// нет специальной операции			нет специальной операции
-			// 10								abcd
-			// 1								5f f
-			// 2						=>		2
-			// abcd								10
-			// 5f f								1
-			sort.Slice(s.lines, func(i, j int) bool {
-				return s.lines[i] > s.lines[j]
-			})
-		}
+	// Запись отсортированных данных в w.
+	for _, str := range s.lines {
+		bw.WriteString(str + "\n")
+	}
 
-	default:
-		switch {
-		case num:
-			// Сортировка чисел.
-			// Работает таким образом, что строки, которые невозможно преобразовать в числа,
-			// сортируются по обычному правилу (в данном случае сортировка по возрастанию).
-			// А строки, которые преобразуются в числа, сортируются по правилу чисел.
-			// В итоговом файле отсортированные числа будут находиться после отсортированных строк.
-			// Пример сортировки:
-			// 35				65gg
-			// 46				qq
-			// 25				w wg
-			// 899				ааа
-			// 6		=>		вв
-			// ааа				6
-			// вв				25
-			// qq				35
-			// 65gg				46
-			// w wg				899
-			sort.Slice(s.lines, func(i, j int) bool {
-				si := s.lines[i]
-				sj := s.lines[j]
+	return bw.Flush()
+}
 
-				di, oki := toNumber(si)
-				dj, okj := toNumber(sj)
+// Возвращает значения ключей сортировки строки line.
+func (s *sorter) lineKeys(line string) []string {
+	values := make([]string, len(s.keys))
+	for i, k := range s.keys {
+		values[i] = k.extract(line)
+	}
 
-				if oki && okj {
-					return di < dj
-				}
-				if oki && !okj {
-					return false
-				}
-				if !oki && okj {
-					return true
-				}
-				return si < sj
-			})
+	return values
+}
 
-		default:
-			// Сортировка строк.
-			// Пример сортировки:
-			// нет специальной операции			1
-			// 10								10
-			// 1								2
-			// 2						=>		5f f
-			// abcd								abcd
-			// 5f f								нет специальной операции
-			sort.Strings(s.lines)
+// Возвращает слово в столбце k строки line, в которой слова разделены строкой sep,
+// или "", если в строке меньше k слов.
+func field(line string, k int, sep string) string {
+	for i := 1; i < k; i++ {
+		idx := strings.Index(line, sep)
+		if idx < 0 {
+			return ""
 		}
+		line = line[idx+len(sep):]
 	}
-}
 
-// Создает двумерный слайс sorter.matrixLines.
-func (s *sorter) createMatrix() {
-	for _, val := range s.lines {
-		s.matrixLines = append(s.matrixLines, strings.Split(val, " "))
+	if idx := strings.Index(line, sep); idx >= 0 {
+		return line[:idx]
 	}
+	return line
 }
 
-// Сортировка данных по столбцу. В аргументах k - номер столбца.
-// В функции реализованы также обратная сортировка, сортировка чисел и их комбинации.
-// reverse - флаг обратной сортировки, num - флаг сортировки чисел.
-func (s *sorter) sortByColumn(k int, reverse, num bool) {
-	switch {
-	case reverse:
-		switch {
-		// Обратная сортировка чисел в столбце k.
-		// Работает по такому же принципу, что и обычная сортировка чисел в столбце k,
-		// но данные при этом сортируются в обратном порядке.
-		// Пример обратной сортировки чисел в столбце 3:
-		// She 12 -26378				to the 23
-		// was 923 smiling 156			end. f -256
-		// to the 23			=>		She 12 -26378
-		// end. f -256					was 923 smiling 156
-		// abcd							ff f
-		// ff f							abcd
-		case num:
-			sort.Slice(s.matrixLines, func(i, j int) bool {
-				iCount := len(s.matrixLines[i])
-				jCount := len(s.matrixLines[j])
-
-				var oki, okj bool
-				var di, dj int
-
-				if iCount >= k {
-					di, oki = toNumber(s.matrixLines[i][k-1])
-				}
+// Сравнивает строки a и b со значениями ключей keysA и keysB.
+// Сначала сравниваются ключи. Если ключи равны, то строки сравниваются целиком (кроме флага -s),
+// поэтому порядок строк не зависит от их порядка во входных данных.
+// При флаге -r результат сравнения строк целиком меняется на противоположный.
+func (s *sorter) compare(a string, keysA []string, b string, keysB []string) int {
+	if c := s.compareKeys(keysA, keysB); c != 0 || s.opts.Stable {
+		return c
+	}
 
-				if jCount >= k {
-					dj, okj = toNumber(s.matrixLines[j][k-1])
-				}
+	c := strings.Compare(a, b)
+	if s.opts.Reverse {
+		c = -c
+	}
+	return c
+}
 
-				if oki && okj {
-					return di > dj
-				}
-				if oki && !okj {
-					return true
-				}
-				if !oki && okj {
-					return false
-				}
-				return strings.Join(s.matrixLines[i], " ") > strings.Join(s.matrixLines[j], " ")
-			})
+// Записывает результат сортировки строк в памяти в w с учетом флагов -u, --count, --repeated и --uniques.
+func (s *sorter) writeResult(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	g := newGroupWriter(bw, s)
+	for i, line := range s.lines {
+		g.write(line, s.values[i])
+	}
+	g.flush()
 
-		default:
-			// Обратная сортировка слов в столбце k.
-			// Работает по такому же принципу, что и обычная сортировка по столбцу, но только данные
-			// сортируются в обратном порядке.
-			// Пример обратной сортировки по столбцу 2:
-			// Then,						going now. I'll
-			// I'll be						I'll be
-			// 2 3 0 a						come back when
-			// going now. I'll		=>		it's all
-			// come back when				2 3 0 a
-			// 3d 3							3d 3
-			// it's all						over.
-			// over.						Then,
-			sort.Slice(s.matrixLines, func(i, j int) bool {
-				iCount := len(s.matrixLines[i])
-				jCount := len(s.matrixLines[j])
+	return bw.Flush()
+}
 
-				if iCount >= k && jCount >= k {
-					return s.matrixLines[i][k-1] > s.matrixLines[j][k-1]
-				}
-				if iCount < k && jCount < k {
-					return strings.Join(s.matrixLines[i], " ") > strings.Join(s.matrixLines[j], " ")
-				}
-				if iCount < k && jCount >= k {
-					return false
-				}
-				return true
-			})
+// Сравнивает значения ключей по цепочке: следующий ключ сравнивается, только если предыдущие равны.
+// Для ключей с модификатором r результат сравнения меняется на противоположный.
+func (s *sorter) compareKeys(keysA, keysB []string) int {
+	for i, k := range s.keys {
+		c := k.compare(keysA[i], keysB[i])
+		if k.reverse {
+			c = -c
 		}
-
-	default:
-		switch {
-		// Сортировка чисел в столбце k.
-		// Слова в столбце k, которые можно преобразовать в числа, сортируются по правилу чисел.
-		// А если слова в столбце преобразовать нельзя или если в строке столбца с номером k нет,
-		// то эти строки сортируются по обычному правилу.
-		// Строки, которые отсортированы по числу в столбце k, расположены после строк, отсортированных
-		// по обычному правилу.
-		// Пример сортировки чисел в столбце 3:
-		// She 12 -26378				abcd
-		// was 923 smiling 156			ff f
-		// to the 23			=>		was 923 smiling 156
-		// end. f -256					She 12 -26378
-		// abcd							end. f -256
-		// ff f							to the 23
-		case num:
-			sort.Slice(s.matrixLines, func(i, j int) bool {
-				iCount := len(s.matrixLines[i])
-				jCount := len(s.matrixLines[j])
-
-				var oki, okj bool
-				var di, dj int
-
-				if iCount >= k {
-					di, oki = toNumber(s.matrixLines[i][k-1])
-				}
-
-				if jCount >= k {
-					dj, okj = toNumber(s.matrixLines[j][k-1])
-				}
-
-				if oki && okj {
-					return di < dj
-				}
-				if oki && !okj {
-					return false
-				}
-				if !oki && okj {
-					return true
-				}
-				return strings.Join(s.matrixLines[i], " ") < strings.Join(s.matrixLines[j], " ")
-			})
-
-		default:
-			// Сортировка слов в столбце k.
-			// Сортировка работает таким образом, что все строки, которые содержат меньше слов, чем k,
-			// сортируются по обычному правилу (в данном случае по возрастанию).
-			// А строки, в которых есть столбец с номером k, сортируются по слову в этом столбце.
-			// Все отсортированные строки по столбцу k будут находиться после отсортированных строк,
-			// в которых столбца k нет.
-			// Пример сортировки по столбцу 2:
-			// Then,						Then,
-			// I'll be						over.
-			// 2 3 0 a						2 3 0 a
-			// going now. I'll		=>		3d 3
-			// come back when				it's all
-			// 3d 3							come back when
-			// it's all						I'll be
-			// over.						going now. I'll
-			sort.Slice(s.matrixLines, func(i, j int) bool {
-				iCount := len(s.matrixLines[i])
-				jCount := len(s.matrixLines[j])
-
-				if iCount >= k && jCount >= k {
-					return s.matrixLines[i][k-1] < s.matrixLines[j][k-1]
-				}
-				if iCount < k && jCount < k {
-					return strings.Join(s.matrixLines[i], " ") < strings.Join(s.matrixLines[j], " ")
-				}
-				if iCount < k && jCount >= k {
-					return true
-				}
-				return false
-			})
+		if c != 0 {
+			return c
 		}
 	}
-}
 
-// Удаляет повторяющиеся строки в слайсе, либо удаляет строки с повторяющимися столбцами k
-// в двумерном слайсе.
-// isColumn - флаг проверки повторяющихся слов в столбце.
-// k - номер столбца.
-func (s *sorter) createSet(isColumn bool, k int) {
-	switch {
-	case isColumn:
-		// Удаление строк исходного файла с повторяющимся столбцом k.
-		// Если строка не содержит столбца k, то считается, что столбец k в строке равен "".
-		// Таким образом, в результат попадет только одна строка, состоящая из < k строк.
-		// Пример сортировки без повторяющихся слов в столбце 2:
-		// a				n
-		// n				1 22 3
-		// a a		=>		a a
-		// c c c			s c d
-		// a a				1234 g
-		// s c d
-		// 1 22 3
-		// g g q
-		// 1234 g
-
-		// Мапа, в ключах которых будет храниться значение столбца k.
-		// А в значении хранится массив слов строки.
-		set := make(map[string][]string)
-		// Двумерный слайс, куда записывается результат.
-		linesSet := make([][]string, 0)
+	return 0
+}
 
-		for _, str := range s.matrixLines {
-			if len(str) >= k {
-				set[str[k-1]] = str
-				continue
-			}
-			set[""] = str
+// Сортирует строки sorter.lines в памяти в соответствии с параметрами sorter.opts.
+// Примеры сортировки:
+// обычная сортировка			сортировка чисел (-n)		сортировка по столбцу 2 (-k 2)
+// 10				1			35				65gg		Then,				Then,
+// 1				10			25				qq			I'll be				over.
+// 2		=>		2			6		=>		ааа			2 3 0 a		=>		2 3 0 a
+// abcd				5f f		qq				6			come back when		3d 3
+// 5f f				abcd		65gg			25			3d 3				come back when
+// ааа				ааа			ааа				35			over.				I'll be
+func (s *sorter) sortLines() {
+	// Ключи вычисляются один раз для каждой строки, а не при каждом сравнении.
+	// При флаге --parallel ключи вычисляются и строки сортируются параллельно по частям.
+	parts := s.partsCount(len(s.lines))
+	keys := make([][]string, len(s.lines))
+	forEachPart(len(s.lines), parts, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			keys[i] = s.lineKeys(s.lines[i])
 		}
+	})
 
-		for _, value := range set {
-			linesSet = append(linesSet, value)
-		}
+	ls := keyedLines{lines: s.lines, keys: keys, s: s}
+	switch {
+	case parts > 1:
+		s.lines, keys = s.parallelSort(ls, parts)
+	case s.opts.Stable:
+		sort.Stable(ls)
+	default:
+		sort.Sort(ls)
+	}
+	s.values = keys
+}
 
-		s.matrixLines = linesSet
+// Строки и их ключи для сортировки с помощью пакета sort.
+type keyedLines struct {
+	lines []string
+	keys  [][]string
+	s     *sorter
+}
 
-	default:
-		// Удаление повторяющихся строк.
-		// Примеры сортировки без повторяющихся строк:
-		// 1				1
-		// fn				2
-		// b b a d			3
-		// 2		=>		a a
-		// 2				b b a d
-		// 3				fn
-		// fn
-		// a a
+func (l keyedLines) Len() int { return len(l.lines) }
+func (l keyedLines) Less(i, j int) bool {
+	return l.s.compare(l.lines[i], l.keys[i], l.lines[j], l.keys[j]) < 0
+}
+func (l keyedLines) Swap(i, j int) {
+	l.lines[i], l.lines[j] = l.lines[j], l.lines[i]
+	l.keys[i], l.keys[j] = l.keys[j], l.keys[i]
+}
 
-		// Мапа, в ключах которой будут храниться строки.
-		set := make(map[string]struct{})
-		// Слайс из неповторяющихся строк.
-		linesSet := make([]string, 0)
+// Записывает отсортированные строки, объединяя подряд идущие строки с равными ключами в группы,
+// как утилита uniq. Из группы выводится первая строка (флаг -u), перед ней может выводиться размер группы
+// (флаг --count), а группы могут фильтроваться по размеру (флаги --repeated и --uniques).
+// Если ни один из этих флагов не указан, то строки записываются без изменений.
+type groupWriter struct {
+	w *bufio.Writer
+	s *sorter
+	// Первая строка текущей группы и значения ее ключей.
+	first     string
+	firstKeys []string
+	// Количество строк в текущей группе.
+	count int
+}
 
-		for _, str := range s.lines {
-			set[str] = struct{}{}
-		}
+// Конструктор структуры groupWriter.
+func newGroupWriter(w *bufio.Writer, s *sorter) *groupWriter {
+	return &groupWriter{w: w, s: s}
+}
 
-		for key := range set {
-			linesSet = append(linesSet, key)
-		}
+// Добавляет очередную строку line со значениями ключей keys.
+func (g *groupWriter) write(line string, keys []string) {
+	if !g.s.opts.grouped() {
+		g.w.WriteString(line + "\n")
+		return
+	}
 
-		s.lines = linesSet
+	if g.count > 0 && g.s.compareKeys(g.firstKeys, keys) == 0 {
+		g.count++
+		return
 	}
+	g.flush()
+	g.first, g.firstKeys, g.count = line, keys, 1
 }
 
-// Преобразует строку в число.
-// Если строка преобразуется в число, возвращает значение числа и true.
-// Если строку невозможно преобразовать в число, то возвращается 0 и false.
-func toNumber(s string) (int, bool) {
-	d, err := strconv.Atoi(s)
-	if err != nil {
-		return 0, false
+// Записывает текущую группу строк.
+func (g *groupWriter) flush() {
+	opts := g.s.opts
+	switch {
+	case g.count == 0:
+		return
+	case opts.Repeated && g.count < 2, opts.Uniques && g.count > 1:
+		// Группа не подходит по размеру.
+	case opts.Count:
+		fmt.Fprintf(g.w, "%7d %s\n", g.count, g.first)
+	default:
+		g.w.WriteString(g.first + "\n")
 	}
-	return d, true
+	g.count = 0
 }
@@ -1,16 +1,41 @@
 package sorter
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"os"
 	"strings"
 	"testing"
 )
 
+// Читает строки из файла с именем name в s.
+func readFile(t *testing.T, s *sorter, name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	assert.NoError(t, s.readLines(f))
+}
+
+// Сортирует строки s и возвращает результат так, как он записывается в выходные данные.
+func sortedResult(t *testing.T, s *sorter) string {
+	s.sortLines()
+
+	var result strings.Builder
+	assert.NoError(t, s.writeResult(&result))
+	return strings.TrimSuffix(result.String(), "\n")
+}
+
 func TestSimpleSort(t *testing.T) {
 	// Тестирование сортировки строк
 
 	source := "test_files/simple_sort_line.txt"
-	s := newSorter(source, "")
+	s := newSorter(Options{})
 	expected := `1
 10
 2
@@ -27,8 +52,8 @@ had no interest
 strange
 there was a famous`
 
-	s.readFile()
-	s.simpleSort(false, false)
+	readFile(t, &s, source)
+	s.sortLines()
 
 	result := strings.Join(s.lines, "\n")
 	assert.Equal(t, expected, result)
@@ -36,7 +61,7 @@ there was a famous`
 	// Тестирование обратной сортировки строк
 
 	source = "test_files/simple_sort_line.txt"
-	s = newSorter(source, "")
+	s = newSorter(Options{Reverse: true})
 	expected = `there was a famous
 strange
 had no interest
@@ -53,22 +78,22 @@ Xian Le
 10
 1`
 
-	s.readFile()
-	s.simpleSort(true, false)
+	readFile(t, &s, source)
+	s.sortLines()
 
 	result = strings.Join(s.lines, "\n")
 	assert.Equal(t, result, expected)
 
-	// Тестирование сортировки чисел
+	// Тестирование сортировки чисел.
+	// Строки, которые не начинаются с числа, равны нулю, а число читается из начала строки.
 
 	source = "test_files/simple_sort_numbers.txt"
-	s = newSorter(source, "")
-	expected = `300 a
-ancient country
-had no interest
--643
+	s = newSorter(Options{Numeric: true})
+	expected = `-643
 0
 0
+ancient country
+had no interest
 1
 2
 10
@@ -77,10 +102,11 @@ had no interest
 45
 238
 300
-300`
+300
+300 a`
 
-	s.readFile()
-	s.simpleSort(false, true)
+	readFile(t, &s, source)
+	s.sortLines()
 
 	result = strings.Join(s.lines, "\n")
 	assert.Equal(t, expected, result)
@@ -88,8 +114,9 @@ had no interest
 	// Тестирование обратной сортировки чисел
 
 	source = "test_files/simple_sort_numbers.txt"
-	s = newSorter(source, "")
-	expected = `300
+	s = newSorter(Options{Reverse: true, Numeric: true})
+	expected = `300 a
+300
 300
 238
 45
@@ -98,15 +125,14 @@ had no interest
 10
 2
 1
-0
-0
--643
 had no interest
 ancient country
-300 a`
+0
+0
+-643`
 
-	s.readFile()
-	s.simpleSort(true, true)
+	readFile(t, &s, source)
+	s.sortLines()
 
 	result = strings.Join(s.lines, "\n")
 	assert.Equal(t, expected, result)
@@ -114,7 +140,7 @@ ancient country
 	// Тестирование сортировки с выводом уникальных строк
 
 	source = "test_files/simple_sort_line.txt"
-	s = newSorter(source, "")
+	s = newSorter(Options{Unique: true})
 	expected = `1
 10
 2
@@ -128,140 +154,650 @@ had no interest
 strange
 there was a famous`
 
-	s.readFile()
-	s.createSet(false, 0)
-	s.simpleSort(false, false)
+	readFile(t, &s, source)
 
-	result = strings.Join(s.lines, "\n")
+	result = sortedResult(t, &s)
 	assert.Equal(t, expected, result)
 }
 
 func TestSortByColumn(t *testing.T) {
-	// Тестирование сортировки по столбцу
+	// Тестирование сортировки по столбцу.
+	// Строки с равными значениями столбца сравниваются целиком.
 
 	source := "test_files/sort_by_column_line.txt"
-	s := newSorter(source, "")
+	s := newSorter(Options{Column: 2})
 	expected := `earnest
 that
 This 10
 speech and behaviour
 и and
-their glazed roofs
 their glazed
+their glazed roofs
 can redeem yourself
 can redeem yourself
 Originally there had been nothing there`
-	result := make([]string, 0)
 	resultStr := ""
 
-	s.readFile()
-	s.createMatrix()
-	s.sortByColumn(2, false, false)
+	readFile(t, &s, source)
+	s.sortLines()
 
-	for _, v := range s.matrixLines {
-		result = append(result, strings.Join(v, " "))
-	}
-	resultStr = strings.Join(result, "\n")
+	resultStr = strings.Join(s.lines, "\n")
 	assert.Equal(t, expected, resultStr)
 
 	// Тестирование обратной сортировки по столбцу
 
 	source = "test_files/sort_by_column_line.txt"
-	s = newSorter(source, "")
+	s = newSorter(Options{Column: 2, Reverse: true})
 	expected = `Originally there had been nothing there
 can redeem yourself
 can redeem yourself
 their glazed roofs
 their glazed
-speech and behaviour
 и and
+speech and behaviour
 This 10
 that
 earnest`
-	result = make([]string, 0)
 
-	s.readFile()
-	s.createMatrix()
-	s.sortByColumn(2, true, false)
+	readFile(t, &s, source)
+	s.sortLines()
 
-	for _, v := range s.matrixLines {
-		result = append(result, strings.Join(v, " "))
-	}
-	resultStr = strings.Join(result, "\n")
+	resultStr = strings.Join(s.lines, "\n")
 	assert.Equal(t, expected, resultStr)
 
 	// Тестирование сортировки чисел по столбцу
 
 	source = "test_files/sort_by_column_number.txt"
-	s = newSorter(source, "")
-	expected = `a A
+	s = newSorter(Options{Column: 2, Numeric: true})
+	expected = `7 -10 7
+1 0 9
+a A
 aa aa
 q
 w
-7 -10 7
-1 0 9
 1 2
 9 6
 0 200
 a 345 0`
-	result = make([]string, 0)
 
-	s.readFile()
-	s.createMatrix()
-	s.sortByColumn(2, false, true)
+	readFile(t, &s, source)
+	s.sortLines()
 
-	for _, v := range s.matrixLines {
-		result = append(result, strings.Join(v, " "))
-	}
-	resultStr = strings.Join(result, "\n")
+	resultStr = strings.Join(s.lines, "\n")
 	assert.Equal(t, expected, resultStr)
 
 	// Тестирование обратной сортировки чисел по столбцу
 
 	source = "test_files/sort_by_column_number.txt"
-	s = newSorter(source, "")
+	s = newSorter(Options{Column: 2, Reverse: true, Numeric: true})
 	expected = `a 345 0
 0 200
 9 6
 1 2
-1 0 9
-7 -10 7
 w
 q
 aa aa
-a A`
-	result = make([]string, 0)
+a A
+1 0 9
+7 -10 7`
 
-	s.readFile()
-	s.createMatrix()
-	s.sortByColumn(2, true, true)
+	readFile(t, &s, source)
+	s.sortLines()
 
-	for _, v := range s.matrixLines {
-		result = append(result, strings.Join(v, " "))
-	}
-	resultStr = strings.Join(result, "\n")
+	resultStr = strings.Join(s.lines, "\n")
 	assert.Equal(t, expected, resultStr)
 
-	// Тестирование сортировки по столбцу с выводом уникальных строк
+	// Тестирование сортировки по столбцу с выводом уникальных строк.
+	// Из строк с равным значением столбца остается первая во входных данных.
 
 	source = "test_files/sort_by_column_line.txt"
-	s = newSorter(source, "")
-	expected = `that
+	s = newSorter(Options{Column: 2, Unique: true})
+	expected = `earnest
 This 10
-и and
-their glazed
+speech and behaviour
+their glazed roofs
 can redeem yourself
 Originally there had been nothing there`
-	result = make([]string, 0)
 
-	s.readFile()
-	s.createMatrix()
-	s.createSet(true, 2)
-	s.sortByColumn(2, false, false)
+	readFile(t, &s, source)
 
-	for _, v := range s.matrixLines {
-		result = append(result, strings.Join(v, " "))
-	}
-	resultStr = strings.Join(result, "\n")
+	resultStr = sortedResult(t, &s)
 	assert.Equal(t, expected, resultStr)
 }
+
+func TestSortReader(t *testing.T) {
+	// Тестирование внешней сортировки: результат сортировки данных по частям
+	// должен совпадать с результатом сортировки всех данных в памяти.
+
+	testData := []struct {
+		source string
+		opts   Options
+	}{
+		{source: "test_files/simple_sort_line.txt"},
+		{source: "test_files/simple_sort_line.txt", opts: Options{Reverse: true}},
+		{source: "test_files/simple_sort_line.txt", opts: Options{Unique: true}},
+		{source: "test_files/simple_sort_numbers.txt", opts: Options{Numeric: true}},
+		{source: "test_files/simple_sort_numbers.txt", opts: Options{Numeric: true, Reverse: true}},
+		{source: "test_files/sort_by_column_number.txt", opts: Options{Column: 2, Numeric: true}},
+		{source: "test_files/sort_by_column_number.txt", opts: Options{Column: 2, Numeric: true, Reverse: true}},
+		{source: "test_files/sort_by_column_line.txt", opts: Options{Column: 2, Unique: true}},
+		{source: "test_files/simple_sort_numbers.txt", opts: Options{Numeric: true, Unique: true}},
+		{source: "test_files/simple_sort_line.txt", opts: Options{FoldCase: true, Stable: true}},
+		{source: "test_files/sort_by_column_line.txt", opts: Options{Column: 2, Stable: true, Reverse: true}},
+		{source: "test_files/sort_by_column_number.txt", opts: Options{Column: 2, Version: true}},
+		{source: "test_files/sort_by_column_number.txt", opts: Options{Keys: []Key{{StartField: 3, EndField: 3, Numeric: true}, {StartField: 1, Reverse: true}}, Unique: true}},
+		{source: "test_files/sort_by_column_line.txt", opts: Options{Keys: []Key{{StartField: 2, StartChar: 2, EndField: 2, EndChar: 3}}, Unique: true}},
+		{source: "test_files/simple_sort_line.txt", opts: Options{FoldCase: true, Count: true}},
+		{source: "test_files/sort_by_column_line.txt", opts: Options{Column: 2, Repeated: true}},
+	}
+
+	defer func(n int) { maxMergeChunks = n }(maxMergeChunks)
+	for _, data := range testData {
+		source, err := os.ReadFile(data.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var expected, result strings.Builder
+		assert.NoError(t, SortReader(bytes.NewReader(source), &expected, data.opts))
+
+		opts := data.opts
+		opts.BufferSize = 50
+		opts.TempDir = t.TempDir()
+		// Части данных сливаются как за один проход, так и за несколько.
+		for _, mergeChunks := range []int{16, 2} {
+			maxMergeChunks = mergeChunks
+			result.Reset()
+			assert.NoError(t, SortReader(bytes.NewReader(source), &result, opts))
+			assert.Equal(t, expected.String(), result.String(), data)
+
+			// Временные файлы удаляются после сортировки.
+			tempFiles, err := os.ReadDir(opts.TempDir)
+			assert.NoError(t, err)
+			assert.Empty(t, tempFiles)
+		}
+	}
+}
+
+func TestSortReader_longLines(t *testing.T) {
+	// Длина строк не ограничена размером буфера bufio.Scanner (64 КиБ) ни при чтении данных,
+	// ни при слиянии временных файлов, ни при проверке порядка.
+	long := strings.Repeat("b", 1<<17)
+	input := long + "a\n" + "c\r\n" + long + "\na"
+	expected := "a\n" + long + "\n" + long + "a\n" + "c\n"
+
+	var result strings.Builder
+	assert.NoError(t, SortReader(strings.NewReader(input), &result, Options{}))
+	assert.Equal(t, expected, result.String())
+
+	result.Reset()
+	assert.NoError(t, SortReader(strings.NewReader(input), &result, Options{BufferSize: 1, TempDir: t.TempDir()}))
+	assert.Equal(t, expected, result.String())
+
+	assert.NoError(t, Check(strings.NewReader(expected), Options{}))
+	var disorder *DisorderError
+	assert.ErrorAs(t, Check(strings.NewReader(input), Options{}), &disorder)
+}
+
+func TestSortReader_flags(t *testing.T) {
+	// Тестирование флагов -M, -h, -b, -f, -V, -g, -t и -s и их комбинаций.
+
+	testData := []struct {
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			input:    "Mar\nfoo\njan\n  Feb\nDEC x\n",
+			opts:     Options{Month: true},
+			expected: "foo\njan\n  Feb\nMar\nDEC x\n",
+		},
+		{
+			input:    "2K\n1G\n1023M\n-1K\n5\nabc\n-2M\n",
+			opts:     Options{HumanNumeric: true},
+			expected: "-2M\n-1K\nabc\n5\n2K\n1023M\n1G\n",
+		},
+		{
+			input:    "  b\na\n\tc\n",
+			opts:     Options{IgnoreLeadingBlanks: true},
+			expected: "a\n  b\n\tc\n",
+		},
+		{
+			input:    "b\nA\na\nB\n",
+			opts:     Options{FoldCase: true},
+			expected: "A\na\nB\nb\n",
+		},
+		{
+			input:    "1.10\n1.2\n1.0~rc1\n1.0\n1.2a\n1.02\n",
+			opts:     Options{Version: true},
+			expected: "1.0~rc1\n1.0\n1.02\n1.2\n1.2a\n1.10\n",
+		},
+		{
+			input:    "1e3\n-inf\n3.5\nabc\nnan\n+7\n",
+			opts:     Options{GeneralNumeric: true},
+			expected: "abc\nnan\n-inf\n3.5\n+7\n1e3\n",
+		},
+		{
+			input:    "a,3\nb,1\nc,2\n",
+			opts:     Options{Column: 2, Separator: ','},
+			expected: "b,1\nc,2\na,3\n",
+		},
+		{
+			input:    "x, 10\ny,9\n",
+			opts:     Options{Column: 2, Separator: ',', IgnoreLeadingBlanks: true, GeneralNumeric: true, Reverse: true},
+			expected: "x, 10\ny,9\n",
+		},
+		{
+			input:    "b 2\na 1\nb 1\na 2\n",
+			opts:     Options{Column: 1},
+			expected: "a 1\na 2\nb 1\nb 2\n",
+		},
+		{
+			input:    "b 2\na 1\nb 1\na 2\n",
+			opts:     Options{Column: 1, Stable: true},
+			expected: "a 1\na 2\nb 2\nb 1\n",
+		},
+		{
+			input:    "b 2\na 1\nb 1\na 2\n",
+			opts:     Options{Column: 1, Stable: true, Reverse: true},
+			expected: "b 2\nb 1\na 1\na 2\n",
+		},
+		{
+			input:    "JAN b\nfeb B\njan B\n",
+			opts:     Options{Column: 2, FoldCase: true, Unique: true},
+			expected: "JAN b\n",
+		},
+	}
+
+	for _, data := range testData {
+		var result strings.Builder
+		assert.NoError(t, SortReader(strings.NewReader(data.input), &result, data.opts))
+		assert.Equal(t, data.expected, result.String(), data.opts)
+	}
+}
+
+func TestSortReader_groups(t *testing.T) {
+	// Тестирование вывода групп строк с равными ключами: -u, --count, --repeated и --uniques.
+
+	input := "b 1\na 2\nc 3\nB 4\na 5\nb 6\n"
+	testData := []struct {
+		opts     Options
+		expected string
+	}{
+		{
+			opts:     Options{Keys: []Key{{StartField: 1, EndField: 1}}, Unique: true},
+			expected: "B 4\na 2\nb 1\nc 3\n",
+		},
+		{
+			opts:     Options{Keys: []Key{{StartField: 1, EndField: 1, FoldCase: true}}, Unique: true},
+			expected: "a 2\nb 1\nc 3\n",
+		},
+		{
+			opts:     Options{Keys: []Key{{StartField: 1, EndField: 1}}, Unique: true, Reverse: true},
+			expected: "c 3\nb 1\na 2\nB 4\n",
+		},
+		{
+			opts:     Options{Keys: []Key{{StartField: 1, EndField: 1, FoldCase: true}}, Count: true},
+			expected: "      2 a 2\n      3 b 1\n      1 c 3\n",
+		},
+		{
+			opts:     Options{Keys: []Key{{StartField: 1, EndField: 1}}, Repeated: true},
+			expected: "a 2\nb 1\n",
+		},
+		{
+			opts:     Options{Keys: []Key{{StartField: 1, EndField: 1}}, Uniques: true},
+			expected: "B 4\nc 3\n",
+		},
+		{
+			opts:     Options{Keys: []Key{{StartField: 1, EndField: 1}}, Uniques: true, Count: true},
+			expected: "      1 B 4\n      1 c 3\n",
+		},
+		{
+			// Без ключей повторяющимися считаются одинаковые строки.
+			opts:     Options{Count: true},
+			expected: "      1 B 4\n      1 a 2\n      1 a 5\n      1 b 1\n      1 b 6\n      1 c 3\n",
+		},
+	}
+
+	for _, data := range testData {
+		var result strings.Builder
+		assert.NoError(t, SortReader(strings.NewReader(input), &result, data.opts))
+		assert.Equal(t, data.expected, result.String(), data.opts)
+
+		// Внешняя сортировка объединяет группы строк так же.
+		result.Reset()
+		opts := data.opts
+		opts.BufferSize = 1
+		opts.TempDir = t.TempDir()
+		assert.NoError(t, SortReader(strings.NewReader(input), &result, opts))
+		assert.Equal(t, data.expected, result.String(), data.opts)
+	}
+}
+
+func TestSortReader_numbers(t *testing.T) {
+	// Тестирование сортировки чисел с дробной частью, отрицательных чисел, чисел больше int64,
+	// чисел с разделителями разрядов и строк, которые начинаются с числа.
+
+	testData := []struct {
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			input: "3.5\n-0\n12abc\n  7\n-1.25\n99999999999999999999999\n100000000000000000000000\n-.5\n+7\n" +
+				"1e3\n0.50\n.5\n007\n5.\n-99999999999999999999999\n",
+			opts: Options{Numeric: true},
+			expected: "-99999999999999999999999\n-1.25\n-.5\n+7\n-0\n.5\n0.50\n1e3\n3.5\n5.\n  7\n007\n12abc\n" +
+				"99999999999999999999999\n100000000000000000000000\n",
+		},
+		{
+			// Локаль с десятичной запятой и разделителем разрядов пробелом, например ru_RU.
+			input:    "1 000,5\n999,75\n1 000\n-2 500\n1  000\n",
+			opts:     Options{Numeric: true, DecimalPoint: ',', ThousandsSeparator: ' '},
+			expected: "-2 500\n1  000\n999,75\n1 000\n1 000,5\n",
+		},
+		{
+			input:    "1,234\n999\n1,2345\n",
+			opts:     Options{Numeric: true, ThousandsSeparator: ','},
+			expected: "999\n1,234\n1,2345\n",
+		},
+		{
+			input:    "1,5K\n1,25K\n900\n",
+			opts:     Options{HumanNumeric: true, DecimalPoint: ','},
+			expected: "900\n1,25K\n1,5K\n",
+		},
+		{
+			input:    "1e3\n-inf\nINF\nnan\nx\n2.5e-400\n0\n1e400\n+7\n3.5x\n-1e400\n.5e1\n1e\n",
+			opts:     Options{GeneralNumeric: true},
+			expected: "x\nnan\n-inf\n-1e400\n0\n2.5e-400\n1e\n3.5x\n.5e1\n+7\n1e3\n1e400\nINF\n",
+		},
+		{
+			// Числа, которые равны после преобразования в float64.
+			input:    "1000.0000000000000000001\n1000\n1e3\n999.99999999999999999999\n",
+			opts:     Options{GeneralNumeric: true},
+			expected: "999.99999999999999999999\n1000\n1e3\n1000.0000000000000000001\n",
+		},
+		{
+			input:    "2,5e1\n3\n",
+			opts:     Options{GeneralNumeric: true, DecimalPoint: ','},
+			expected: "3\n2,5e1\n",
+		},
+	}
+
+	for _, data := range testData {
+		var result strings.Builder
+		assert.NoError(t, SortReader(strings.NewReader(data.input), &result, data.opts))
+		assert.Equal(t, data.expected, result.String(), data.input)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	// Тестирование разбора описания ключа в формате флага -k.
+
+	testData := []struct {
+		spec     string
+		expected Key
+	}{
+		{spec: "2", expected: Key{StartField: 2}},
+		{spec: "2,2", expected: Key{StartField: 2, EndField: 2}},
+		{spec: "2,2n", expected: Key{StartField: 2, EndField: 2, Numeric: true}},
+		{spec: "1.3,1.5r", expected: Key{StartField: 1, StartChar: 3, EndField: 1, EndChar: 5, Reverse: true}},
+		{spec: "3b,3.0", expected: Key{StartField: 3, EndField: 3, IgnoreLeadingBlanks: true}},
+		{spec: "1fr", expected: Key{StartField: 1, FoldCase: true, Reverse: true}},
+		{spec: "2,3Mb", expected: Key{StartField: 2, EndField: 3, Month: true, IgnoreLeadingBlanks: true}},
+	}
+
+	for _, data := range testData {
+		k, err := ParseKey(data.spec)
+		assert.NoError(t, err, data.spec)
+		assert.Equal(t, data.expected, k, data.spec)
+	}
+
+	for _, spec := range []string{"", "0", "1.0", "a", "1.", "1,", "1,0", "1x", "1.2.3", "-1"} {
+		_, err := ParseKey(spec)
+		assert.ErrorIs(t, err, ErrInvalidColumn, spec)
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	// Тестирование выделения значения ключа из строки.
+
+	testData := []struct {
+		spec     string
+		sep      rune
+		line     string
+		expected string
+	}{
+		{spec: "1", line: "a b c", expected: "a b c"},
+		{spec: "2", line: "a b c", expected: " b c"},
+		{spec: "2,2", line: "a  b c", expected: "  b"},
+		{spec: "2b,2", line: "a  b c", expected: "b"},
+		{spec: "4,4", line: "a b c", expected: ""},
+		{spec: "1.2,1.3", line: "abcd e", expected: "bc"},
+		{spec: "2.2,2.3", line: "a  bcd", expected: " b"},
+		{spec: "2.2b,2.3", line: "a  bcd", expected: "cd"},
+		{spec: "1.2,1.10", line: "привет мир", expected: "ривет"},
+		{spec: "2,2", sep: ',', line: "a,,c", expected: ""},
+		{spec: "2,3", sep: ',', line: "a, b,c,d", expected: " b,c"},
+		{spec: "2", sep: ';', line: "a;b;c", expected: "b;c"},
+		{spec: "3,2", line: "a b c", expected: ""},
+	}
+
+	for _, data := range testData {
+		k, err := ParseKey(data.spec)
+		assert.NoError(t, err)
+		assert.Equal(t, data.expected, k.value(data.line, data.sep), data)
+	}
+}
+
+func TestSortReader_keys(t *testing.T) {
+	// Тестирование сортировки по нескольким ключам с модификаторами.
+
+	testData := []struct {
+		keys     []string
+		opts     Options
+		input    string
+		expected string
+	}{
+		{
+			// -k2,2n -k1,1r
+			keys:     []string{"2,2n", "1,1r"},
+			input:    "a 10\nb 2\nc 10\nd 2\n",
+			expected: "d 2\nb 2\nc 10\na 10\n",
+		},
+		{
+			// Ключ без модификаторов использует глобальные флаги.
+			keys:     []string{"2,2"},
+			opts:     Options{Numeric: true, Reverse: true},
+			input:    "a 1\nb 3\nc 2\n",
+			expected: "b 3\nc 2\na 1\n",
+		},
+		{
+			// Ключ со своими модификаторами не использует глобальные флаги.
+			keys:     []string{"2,2n"},
+			opts:     Options{Reverse: true},
+			input:    "a 1\nb 3\nb 1\n",
+			expected: "b 1\na 1\nb 3\n",
+		},
+		{
+			keys:     []string{"2,2n", "1.1,1.3M"},
+			input:    "Mar 10\nJan 10\nFeb 09\n",
+			expected: "Feb 09\nJan 10\nMar 10\n",
+		},
+		{
+			keys:     []string{"3,3", "1,1V"},
+			opts:     Options{Separator: ':'},
+			input:    "v1.10:x:b\nv1.2:y:b\nv1.3:z:a\n",
+			expected: "v1.3:z:a\nv1.2:y:b\nv1.10:x:b\n",
+		},
+		{
+			keys:     []string{"2,2f"},
+			opts:     Options{Stable: true},
+			input:    "1 b\n2 A\n3 B\n4 a\n",
+			expected: "2 A\n4 a\n1 b\n3 B\n",
+		},
+	}
+
+	for _, data := range testData {
+		opts := data.opts
+		for _, spec := range data.keys {
+			k, err := ParseKey(spec)
+			assert.NoError(t, err)
+			opts.Keys = append(opts.Keys, k)
+		}
+
+		var result strings.Builder
+		assert.NoError(t, SortReader(strings.NewReader(data.input), &result, opts))
+		assert.Equal(t, data.expected, result.String(), data.keys)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// Тестирование проверки отсортированности (флаги -c и -C).
+	// expectedLine - номер первой строки, которая нарушает порядок, или 0, если строки отсортированы.
+
+	testData := []struct {
+		input        string
+		opts         Options
+		expectedLine int
+	}{
+		{input: "a\nb\nb\nc\n", expectedLine: 0},
+		{input: "a\nc\nb\nd\n", expectedLine: 3},
+		{input: "a\nb\nb\nc\n", opts: Options{Unique: true}, expectedLine: 3},
+		{input: "2\n10\n", opts: Options{Numeric: true}, expectedLine: 0},
+		{input: "2\n10\n", expectedLine: 2},
+		{input: "x 2\ny 1\n", opts: Options{Column: 2, Reverse: true}, expectedLine: 0},
+		{input: "Feb\nJan\n", opts: Options{Month: true}, expectedLine: 2},
+		{input: "", expectedLine: 0},
+	}
+
+	for _, data := range testData {
+		err := Check(strings.NewReader(data.input), data.opts)
+		if data.expectedLine == 0 {
+			assert.NoError(t, err, data.input)
+			continue
+		}
+
+		var disorder *DisorderError
+		if assert.ErrorAs(t, err, &disorder, data.input) {
+			assert.Equal(t, data.expectedLine, disorder.Line)
+		}
+	}
+}
+
+// Генерирует n случайных строк из трех столбцов: слово, число и число с плавающей точкой.
+// Строки повторяются, чтобы в данных были равные ключи.
+func generateLines(n int) string {
+	r := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		word := make([]byte, 1+r.Intn(8))
+		for j := range word {
+			word[j] = byte('a' + r.Intn(26))
+		}
+		fmt.Fprintf(&b, "%s %d %.3f\n", word, r.Intn(n/4+1)-n/8, r.NormFloat64()*1e3)
+	}
+	return b.String()
+}
+
+func TestSortReader_parallel(t *testing.T) {
+	// Тестирование параллельной сортировки: результат должен совпадать с результатом сортировки в одном потоке.
+
+	// Уменьшаем размер частей, чтобы данные делились на части в тестах.
+	defer func(n int) { minParallelLines = n }(minParallelLines)
+	minParallelLines = 100
+
+	input := generateLines(5000)
+	testData := []Options{
+		{},
+		{Reverse: true},
+		{Keys: []Key{{StartField: 2, EndField: 2, Numeric: true}}},
+		{Keys: []Key{{StartField: 2, EndField: 2, Numeric: true}}, Stable: true},
+		{Keys: []Key{{StartField: 1, StartChar: 1, EndField: 1, EndChar: 1}}, Unique: true},
+		{Keys: []Key{{StartField: 3, GeneralNumeric: true, Reverse: true}}, Count: true},
+	}
+
+	for _, opts := range testData {
+		var expected strings.Builder
+		assert.NoError(t, SortReader(strings.NewReader(input), &expected, opts))
+
+		for _, parallel := range []int{2, 3, 4, 8} {
+			var result strings.Builder
+			opts.Parallel = parallel
+			assert.NoError(t, SortReader(strings.NewReader(input), &result, opts))
+			assert.True(t, expected.String() == result.String(), "parallel=%d %+v", parallel, opts)
+		}
+
+		// Параллельная сортировка частей при внешней сортировке.
+		var result strings.Builder
+		opts.BufferSize = len(input) / 2
+		opts.TempDir = t.TempDir()
+		assert.NoError(t, SortReader(strings.NewReader(input), &result, opts))
+		assert.True(t, expected.String() == result.String(), "external %+v", opts)
+	}
+}
+
+func BenchmarkSortReader(b *testing.B) {
+	// Сравнение сортировки в одном потоке (Parallel: 1) с параллельной сортировкой на 2 млн строк.
+
+	input := generateLines(2000000)
+	testData := []struct {
+		name string
+		opts Options
+	}{
+		{name: "lines"},
+		{name: "numeric", opts: Options{Keys: []Key{{StartField: 2, EndField: 2, Numeric: true}}}},
+		{name: "general", opts: Options{Keys: []Key{{StartField: 3, GeneralNumeric: true}}}},
+	}
+
+	for _, data := range testData {
+		for _, parallel := range []int{1, 2, 4, 8} {
+			opts := data.opts
+			opts.Parallel = parallel
+			// Все данные сортируются в памяти.
+			opts.BufferSize = 1 << 30
+
+			b.Run(fmt.Sprintf("%s/parallel=%d", data.name, parallel), func(b *testing.B) {
+				b.SetBytes(int64(len(input)))
+				for i := 0; i < b.N; i++ {
+					if err := SortReader(strings.NewReader(input), io.Discard, opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSortReader_errors(t *testing.T) {
+	// Тестирование ошибок: функция возвращает ошибку, а не завершает программу.
+
+	var result strings.Builder
+	err := SortReader(strings.NewReader("a\n"), &result, Options{Column: -1})
+	assert.ErrorIs(t, err, ErrInvalidColumn)
+
+	err = SortReader(strings.NewReader("a\n"), &result, Options{Numeric: true, Month: true})
+	assert.ErrorIs(t, err, ErrIncompatibleOptions)
+
+	err = SortReader(strings.NewReader("a\n"), &result, Options{Keys: []Key{{StartField: 1, Numeric: true, Version: true}}})
+	assert.ErrorIs(t, err, ErrIncompatibleOptions)
+
+	err = SortReader(strings.NewReader("a\n"), &result, Options{Parallel: -1})
+	assert.ErrorIs(t, err, ErrInvalidParallel)
+
+	err = SortReader(strings.NewReader("a\n"), &result, Options{Repeated: true, Uniques: true})
+	assert.ErrorIs(t, err, ErrIncompatibleGroups)
+
+	err = SortReader(strings.NewReader("a\n"), &result, Options{Column: 1, Keys: []Key{{StartField: 1}}})
+	assert.ErrorIs(t, err, ErrInvalidColumn)
+
+	err = SortReader(strings.NewReader("a\n"), &result, Options{Keys: []Key{{StartField: 0}}})
+	assert.ErrorIs(t, err, ErrInvalidColumn)
+
+	// Временные файлы невозможно создать.
+	err = SortReader(strings.NewReader("b\na\n"), &result, Options{BufferSize: 1, TempDir: "test_files/nodir"})
+	assert.Error(t, err)
+
+	err = SortFile("test_files/nofile.txt", t.TempDir()+"/result.txt", Options{})
+	assert.Error(t, err)
+}
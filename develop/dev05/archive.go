@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Сигнатуры (первые байты) сжатых данных.
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Размер блока tar-архива. Сигнатура ustar находится в первом блоке по смещению tarMagicOffset.
+const (
+	tarBlockSize   = 512
+	tarMagicOffset = 257
+)
+
+// Возвращает reader, из которого читаются распакованные данные reader.
+// Формат сжатия (gzip, bzip2 или zstd) определяется по первым байтам данных, а не по расширению файла.
+// Если данные не сжаты, то они возвращаются без изменений.
+func decompress(reader *bufio.Reader) (io.ReadCloser, error) {
+	header := peekHeader(reader, isMagicPrefix)
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return gzip.NewReader(reader)
+	case bytes.HasPrefix(header, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(reader)), nil
+	case bytes.HasPrefix(header, zstdMagic):
+		d, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return io.NopCloser(reader), nil
+	}
+}
+
+// Возвращает начало данных для определения их формата. Как и при определении метки BOM (bomHeader в пакете grep),
+// данные читаются одним чтением, чтобы не ждать следующих строк интерактивного ввода или растущего файла.
+// Следующие байты ожидаются, только пока для прочитанных байт incomplete возвращает true.
+// Данные из reader при этом не вычитываются.
+func peekHeader(reader *bufio.Reader, incomplete func(data []byte) bool) []byte {
+	reader.Peek(1)
+	header, _ := reader.Peek(reader.Buffered())
+	for incomplete(header) {
+		if _, err := reader.Peek(len(header) + 1); err != nil {
+			break
+		}
+		header, _ = reader.Peek(reader.Buffered())
+	}
+
+	return header
+}
+
+// Проверяет, являются ли байты data началом, но не целой сигнатурой сжатых данных.
+func isMagicPrefix(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	for _, magic := range [][]byte{gzipMagic, bzip2Magic, zstdMagic} {
+		if len(data) < len(magic) && bytes.HasPrefix(magic, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// Проверяет, является ли содержимое reader tar-архивом, по сигнатуре ustar в первом блоке.
+// Данные из reader при этом не вычитываются.
+func isTar(reader *bufio.Reader) bool {
+	header := peekHeader(reader, func(data []byte) bool {
+		return len(data) < tarBlockSize && isTarHeaderPrefix(data)
+	})
+	return len(header) >= tarBlockSize && bytes.HasPrefix(header[tarMagicOffset:], []byte("ustar"))
+}
+
+// Границы числовых полей заголовка tar: mode, uid, gid, size, mtime и chksum.
+var tarNumericFields = [][2]int{{100, 108}, {108, 116}, {116, 124}, {124, 136}, {136, 148}, {148, 156}}
+
+// Проверяет, могут ли байты data быть началом заголовка tar-архива.
+// Имя файла в заголовке дополняется нулевыми байтами и не содержит переводов строк,
+// числовые поля записаны восьмеричными цифрами (или в двоичном виде, если старший бит первого байта равен 1),
+// а по смещению tarMagicOffset находится сигнатура ustar. Поэтому обычный текст, как правило,
+// перестает быть началом заголовка уже на первой строке.
+func isTarHeaderPrefix(data []byte) bool {
+	name := data
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	if bytes.IndexByte(name, '\n') >= 0 {
+		return false
+	}
+	if i := bytes.IndexByte(name, 0); i >= 0 && len(bytes.Trim(name[i:], "\x00")) > 0 {
+		return false
+	}
+
+	for _, field := range tarNumericFields {
+		if len(data) <= field[0] {
+			break
+		}
+		value := data[field[0]:]
+		if len(value) > field[1]-field[0] {
+			value = value[:field[1]-field[0]]
+		}
+		if value[0]&0x80 != 0 {
+			continue
+		}
+		if len(bytes.Trim(value, "01234567 \x00")) > 0 {
+			return false
+		}
+	}
+
+	if len(data) > tarMagicOffset {
+		magic := data[tarMagicOffset:]
+		if len(magic) > len("ustar") {
+			magic = magic[:len("ustar")]
+		}
+		return bytes.HasPrefix([]byte("ustar"), magic)
+	}
+	return true
+}
+
+// Метод, осуществляющий поиск совпадений в данных из r с флагом -z. name - имя, под которым выводятся строки из r.
+// Сжатые данные распаковываются, а в tar-архиве поиск происходит в каждом файле архива,
+// строки которого выводятся под именем вида archive.tar:member. Архивы внутри архива также распаковываются.
+// Результат записывается в w. Возвращает количество совпадений.
+func (g Grep) archiveSearch(ctx context.Context, name string, r io.Reader, w io.Writer) (int, error) {
+	data, err := decompress(bufio.NewReader(r))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при распаковке %s: %v", name, err)
+	}
+	defer data.Close()
+
+	reader := bufio.NewReaderSize(data, tarBlockSize)
+	if !isTar(reader) {
+		return g.readerSearch(ctx, name, reader, w)
+	}
+
+	// Имена файлов архива выводятся всегда, если не указан флаг -h.
+	if !g.flags.NoFileName {
+		g.flags.WithFileName = true
+	}
+
+	matchesCount := 0
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return matchesCount, nil
+		}
+		if err != nil {
+			return matchesCount, fmt.Errorf("ошибка при чтении архива %s: %v", name, err)
+		}
+		// Поиск происходит только в обычных файлах, директории и ссылки пропускаются.
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		n, err := g.archiveSearch(ctx, name+":"+header.Name, archive, w)
+		matchesCount += n
+		if err != nil {
+			return matchesCount, err
+		}
+		// При флаге -q поиск прекращается после первого совпадения.
+		if g.flags.Quiet && matchesCount > 0 {
+			return matchesCount, nil
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTar(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	tw.WriteHeader(&tar.Header{Name: "logs/app.log", Mode: 0644, Size: 4, Typeflag: tar.TypeReg})
+	tw.Write([]byte("abc\n"))
+	tw.Close()
+
+	testData := []struct {
+		data     string
+		expected bool
+	}{
+		{data: archive.String(), expected: true},
+		{data: "", expected: false},
+		{data: "error one\n", expected: false},
+		{data: strings.Repeat("a", 600), expected: false},
+		{data: "app.log" + strings.Repeat("\x00", 93) + "0000644\x00" + strings.Repeat("\x00", 400), expected: false},
+	}
+
+	for _, data := range testData {
+		assert.Equal(t, data.expected, isTar(bufio.NewReaderSize(strings.NewReader(data.data), tarBlockSize)), data.data)
+	}
+
+	// Начало заголовка tar ожидает следующих данных, а обычный текст - нет.
+	assert.True(t, isTarHeaderPrefix(archive.Bytes()[:300]))
+	assert.False(t, isTarHeaderPrefix([]byte("error one\n")))
+	assert.False(t, isTarHeaderPrefix([]byte("app.log\x00x")))
+}
+
+func TestGrep_archiveSearchStdin(t *testing.T) {
+	// При флаге -z строки интерактивного ввода выводятся сразу, не дожидаясь конца ввода.
+	r, w := io.Pipe()
+	defer w.Close()
+
+	out := &lockedWriter{w: new(bytes.Buffer)}
+	var stderr bytes.Buffer
+	g, err := NewGrep([]string{"-z", "error"}, r, out, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- g.Search(context.Background())
+	}()
+
+	w.Write([]byte("error one\n"))
+	assert.Equal(t, "error one\n", waitOutput(t, out, "error one\n"))
+	w.Write([]byte("info\nerror two\n"))
+	assert.Equal(t, "error one\nerror two\n", waitOutput(t, out, "error one\nerror two\n"))
+
+	w.Close()
+	assert.Equal(t, 0, <-done)
+	assert.Empty(t, stderr.String())
+}
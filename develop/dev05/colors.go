@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Режимы вывода цветов (флаг --color).
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// colorScheme - цвета, которыми подсвечиваются части выводимых строк.
+// Значения - параметры SGR escape-последовательностей, например "01;31".
+// Цвета по умолчанию можно изменить переменной окружения GREP_COLORS в формате GNU grep:
+// GREP_COLORS='ms=01;31:mc=01;31:sl=:cx=:fn=35:ln=32:bn=32:se=36'.
+type colorScheme struct {
+	// Выводить ли цвета.
+	enabled bool
+	// Совпадение в выбранной строке (ms).
+	selectedMatch string
+	// Совпадение в строке контекста (mc).
+	contextMatch string
+	// Выбранная строка целиком (sl).
+	selectedLine string
+	// Строка контекста целиком (cx).
+	contextLine string
+	// Имя файла (fn).
+	fileName string
+	// Номер строки (ln).
+	lineNum string
+	// Смещение в байтах (bn).
+	byteOffset string
+	// Разделитель (se).
+	separator string
+}
+
+// Создает цветовую схему.
+// mode - значение флага --color, isTerminal - выводятся ли данные в терминал,
+// grepColors - значение переменной окружения GREP_COLORS.
+func newColorScheme(mode string, isTerminal bool, grepColors string) (colorScheme, error) {
+	c := colorScheme{
+		selectedMatch: "31",
+		contextMatch:  "31",
+		fileName:      "35",
+		lineNum:       "32",
+		byteOffset:    "32",
+	}
+
+	switch mode {
+	case colorAlways:
+		c.enabled = true
+	case colorAuto:
+		c.enabled = isTerminal
+	case colorNever:
+		c.enabled = false
+	default:
+		return c, fmt.Errorf("некорректное значение флага --color: %s", mode)
+	}
+
+	for _, capability := range strings.Split(grepColors, ":") {
+		name, value, _ := strings.Cut(capability, "=")
+		switch name {
+		case "mt":
+			c.selectedMatch = value
+			c.contextMatch = value
+		case "ms":
+			c.selectedMatch = value
+		case "mc":
+			c.contextMatch = value
+		case "sl":
+			c.selectedLine = value
+		case "cx":
+			c.contextLine = value
+		case "fn":
+			c.fileName = value
+		case "ln":
+			c.lineNum = value
+		case "bn":
+			c.byteOffset = value
+		case "se":
+			c.separator = value
+		}
+	}
+
+	return c, nil
+}
+
+// Подсвечивает строку s цветом sgr, если цвета включены.
+func (c colorScheme) paint(sgr, s string) string {
+	if !c.enabled || sgr == "" || s == "" {
+		return s
+	}
+
+	return "\033[" + sgr + "m" + s + "\033[0m"
+}
+
+// Подсвечивает в строке s совпадения, расположенные на позициях spans, цветом matchColor,
+// а остальную часть строки - цветом lineColor.
+func (c colorScheme) highlight(s string, spans [][]int, matchColor, lineColor string) string {
+	if !c.enabled {
+		return s
+	}
+
+	var b strings.Builder
+	// Позиция в строке, до которой строка уже записана в результат.
+	pos := 0
+	for _, span := range spans {
+		b.WriteString(c.paint(lineColor, s[pos:span[0]]))
+		b.WriteString(c.paint(matchColor, s[span[0]:span[1]]))
+		pos = span[1]
+	}
+	b.WriteString(c.paint(lineColor, s[pos:]))
+
+	return b.String()
+}
+
+// Проверяет, выводятся ли данные в терминал.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorFlag - значение флага --color. Флаг может быть указан без значения, тогда используется режим auto.
+type colorFlag string
+
+func (f *colorFlag) String() string {
+	return string(*f)
+}
+
+func (f *colorFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*f = colorAuto
+	case "false":
+		*f = colorNever
+	case colorAuto, colorAlways, colorNever:
+		*f = colorFlag(value)
+	default:
+		return fmt.Errorf("допустимые значения: %s, %s, %s", colorAuto, colorAlways, colorNever)
+	}
+
+	return nil
+}
+
+func (f *colorFlag) IsBoolFlag() bool {
+	return true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Коды завершения программы, совпадающие с кодами утилиты grep.
+const (
+	// Найдена хотя бы одна строка.
+	exitMatch = 0
+	// Не найдено ни одной строки.
+	exitNoMatch = 1
+	// Произошла ошибка.
+	exitError = 2
+)
+
+// errUsage - ошибка разбора аргументов командной строки.
+// Сообщение о ней и справка по флагам выводятся пакетом flag при разборе.
+var errUsage = errors.New("некорректные аргументы командной строки")
+
+// errorLog - выводит сообщения об ошибках и запоминает, что ошибка произошла.
+// Может использоваться из нескольких горутин одновременно.
+type errorLog struct {
+	// Поток, в который выводятся сообщения об ошибках.
+	w io.Writer
+	// Не выводить сообщения об ошибках чтения файлов (флаг -s).
+	silent bool
+	// Произошла ли хотя бы одна ошибка.
+	failed bool
+	sync.Mutex
+}
+
+// Конструктор структуры errorLog.
+func newErrorLog(w io.Writer, silent bool) *errorLog {
+	return &errorLog{
+		w:      w,
+		silent: silent,
+	}
+}
+
+// Report - запоминает ошибку err и выводит сообщение о ней, если не указан флаг -s.
+func (l *errorLog) Report(err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.failed = true
+	if !l.silent {
+		fmt.Fprintf(l.w, "go-grep: %v\n", err)
+	}
+}
+
+// Failed - возвращает true, если произошла хотя бы одна ошибка.
+func (l *errorLog) Failed() bool {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.failed
+}
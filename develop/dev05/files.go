@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Проверяет, нужно ли искать совпадения в файле с именем name
+// с учетом шаблонов, переданных во флагах --include и --exclude.
+// Шаблоны сравниваются с базовым именем файла.
+func (g Grep) isFileIncluded(name string) bool {
+	base := filepath.Base(name)
+
+	for _, pattern := range g.flags.Exclude {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return false
+		}
+	}
+
+	if len(g.flags.Include) == 0 {
+		return true
+	}
+	for _, pattern := range g.flags.Include {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Проверяет, нужно ли заходить в директорию с именем name при рекурсивном поиске
+// с учетом шаблонов, переданных во флаге --exclude-dir.
+func (g Grep) isDirIncluded(name string) bool {
+	base := filepath.Base(name)
+
+	for _, pattern := range g.flags.ExcludeDir {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Вызывает функцию visit для каждого файла, переданного при запуске программы.
+// Если указан флаг -r или -R, то директории обходятся рекурсивно.
+// При флаге -r символические ссылки внутри директорий пропускаются, при флаге -R - разыменовываются.
+// Если visit возвращает false, то обход прекращается.
+func (g Grep) walkFiles(visit func(name string) bool) {
+	// Множество уже обойденных директорий. Нужно, чтобы не зациклиться на символических ссылках при флаге -R.
+	visited := make(map[string]struct{})
+
+	for _, name := range g.flags.FileNames {
+		// "-" означает стандартный ввод.
+		if name == "-" {
+			if !visit(name) {
+				return
+			}
+			continue
+		}
+
+		info, err := os.Stat(name)
+		if err != nil {
+			g.errs.Report(err)
+			continue
+		}
+
+		if !info.IsDir() {
+			if g.isFileIncluded(name) && !visit(name) {
+				return
+			}
+			continue
+		}
+
+		if !g.flags.Recursive {
+			g.errs.Report(fmt.Errorf("%s: это директория", name))
+			continue
+		}
+
+		if !g.walkDir(name, visited, visit) {
+			return
+		}
+	}
+}
+
+// Рекурсивно обходит директорию root и вызывает функцию visit для каждого найденного файла.
+// Возвращает false, если обход был прекращен функцией visit.
+func (g Grep) walkDir(root string, visited map[string]struct{}, visit func(name string) bool) bool {
+	if realPath, err := filepath.EvalSymlinks(root); err == nil {
+		if absPath, err := filepath.Abs(realPath); err == nil {
+			if _, ok := visited[absPath]; ok {
+				return true
+			}
+			visited[absPath] = struct{}{}
+		}
+	}
+
+	// Был ли обход прекращен функцией visit.
+	stopped := false
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			g.errs.Report(err)
+			return nil
+		}
+
+		switch {
+		case d.IsDir():
+			if path != root && !g.isDirIncluded(path) {
+				return filepath.SkipDir
+			}
+
+		case d.Type()&fs.ModeSymlink != 0:
+			// Символические ссылки разыменовываются только при флаге -R.
+			if !g.flags.Dereference {
+				return nil
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				g.errs.Report(err)
+				return nil
+			}
+			if info.IsDir() {
+				// Разделитель в конце пути нужен, чтобы WalkDir перешел по ссылке, а не вернул саму ссылку.
+				if g.isDirIncluded(path) && !g.walkDir(path+string(filepath.Separator), visited, visit) {
+					stopped = true
+					return filepath.SkipAll
+				}
+				return nil
+			}
+			if info.Mode().IsRegular() && g.isFileIncluded(path) && !visit(path) {
+				stopped = true
+				return filepath.SkipAll
+			}
+
+		case d.Type().IsRegular():
+			if g.isFileIncluded(path) && !visit(path) {
+				stopped = true
+				return filepath.SkipAll
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		g.errs.Report(err)
+	}
+
+	return !stopped
+}
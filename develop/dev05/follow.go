@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Интервал, с которым при флаге --follow проверяется появление новых данных в файле.
+const followInterval = 250 * time.Millisecond
+
+// Количество первых байт файла, по которым определяется, что файл был усечен и снова заполнен.
+const followHeadSize = 1024
+
+// followReader - читает файл, не останавливаясь на его конце (флаг --follow).
+// Когда данные заканчиваются, Read ждет появления новых данных вместо того, чтобы вернуть io.EOF.
+// При ротации (по пути name появился другой файл) чтение продолжается с начала нового файла,
+// а при усечении файла - с начала текущего файла. В обоих случаях Read один раз возвращает io.EOF,
+// чтобы поиск начался заново, а restarted возвращает true.
+// После отмены контекста ctx Read всегда возвращает io.EOF.
+type followReader struct {
+	ctx  context.Context
+	name string
+	file *os.File
+	// Количество байт, прочитанных из текущего файла.
+	offset int64
+	// Первые байты текущего файла (не больше followHeadSize). Если они изменились, то файл был усечен,
+	// даже если после усечения в него было записано больше данных, чем прочитано (copytruncate).
+	head []byte
+	// Было ли начато чтение файла заново после последнего вызова restarted.
+	restart bool
+	// Интервал проверки появления новых данных.
+	interval time.Duration
+}
+
+// Конструктор структуры followReader. file - открытый файл с именем name.
+func newFollowReader(ctx context.Context, file *os.File, name string, interval time.Duration) *followReader {
+	return &followReader{
+		ctx:      ctx,
+		name:     name,
+		file:     file,
+		interval: interval,
+	}
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.file.Read(p)
+		if n > 0 {
+			// Запоминаем первые байты файла.
+			if rest := followHeadSize - len(r.head); rest > 0 && r.offset == int64(len(r.head)) {
+				if rest > n {
+					rest = n
+				}
+				r.head = append(r.head, p[:rest]...)
+			}
+			r.offset += int64(n)
+			return n, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		reset, err := r.wait()
+		if err != nil {
+			return 0, err
+		}
+		if reset {
+			r.restart = true
+			return 0, io.EOF
+		}
+	}
+}
+
+// Возвращает true, если после предыдущего вызова файл был заменен или усечен и читается с начала.
+func (r *followReader) restarted() bool {
+	restart := r.restart
+	r.restart = false
+	return restart
+}
+
+// Ждет interval и проверяет, не был ли файл заменен или усечен.
+// Возвращает true, если чтение начинается с начала файла, и io.EOF, если контекст отменен.
+func (r *followReader) wait() (bool, error) {
+	select {
+	case <-r.ctx.Done():
+		return false, io.EOF
+	case <-time.After(r.interval):
+	}
+
+	current, err := r.file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// Если файл был усечен, то чтение начинается с начала файла. Если после усечения в файл
+	// успели записать больше данных, чем было прочитано, то усечение определяется по изменению первых байт.
+	truncated, err := r.truncated(current.Size())
+	if err != nil {
+		return false, err
+	}
+	if truncated {
+		if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		r.offset = 0
+		r.head = nil
+		return true, nil
+	}
+
+	// Если в текущий файл были дописаны данные, то сначала дочитываются они.
+	if current.Size() > r.offset {
+		return false, nil
+	}
+
+	// Если файл по пути name был заменен (например, при ротации логов), то открывается новый файл.
+	// Пока новый файл не создан, чтение продолжается из старого.
+	if info, err := os.Stat(r.name); err == nil && !os.SameFile(info, current) {
+		f, err := os.Open(r.name)
+		if err != nil {
+			return false, nil
+		}
+		r.file.Close()
+		r.file = f
+		r.offset = 0
+		r.head = nil
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Проверяет, был ли усечен текущий файл размера size: файл стал меньше прочитанного
+// или его первые байты отличаются от прочитанных.
+func (r *followReader) truncated(size int64) (bool, error) {
+	if size < r.offset {
+		return true, nil
+	}
+	if len(r.head) == 0 {
+		return false, nil
+	}
+
+	head := make([]byte, len(r.head))
+	if _, err := r.file.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return !bytes.Equal(head, r.head), nil
+}
+
+// Close - закрывает текущий файл.
+func (r *followReader) Close() error {
+	return r.file.Close()
+}
+
+// Осуществляет поиск в файле name с флагом --follow функцией search. После ротации или усечения файла
+// поиск начинается заново, поэтому номера строк, смещения и количество совпадений (флаг -c)
+// отсчитываются от начала нового файла. Возвращает общее количество совпадений.
+func (g Grep) followSearch(ctx context.Context, name string, r *followReader, w io.Writer,
+	search func(ctx context.Context, name string, r io.Reader, w io.Writer) (int, error)) (int, error) {
+	// После завершения работы функции файл закрывается.
+	defer r.Close()
+
+	matchesCount := 0
+	for {
+		n, err := search(ctx, name, r, w)
+		matchesCount += n
+		if err != nil || !r.restarted() {
+			return matchesCount, err
+		}
+	}
+}
+
+// Поток вывода, в который могут одновременно писать несколько горутин.
+type lockedWriter struct {
+	w io.Writer
+	sync.Mutex
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.w.Write(p)
+}
+
+// Осуществляет поиск совпадений во всех файлах с флагом --follow и записывает результат в w.
+// Поиск в каждом файле происходит в отдельной горутине до отмены контекста ctx,
+// строки выводятся по мере появления в файлах. Возвращает общее количество совпадений.
+func (g Grep) followFiles(ctx context.Context, w io.Writer) int {
+	out := &lockedWriter{w: w}
+	matchesCount := 0
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, name := range g.flags.FileNames {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			n, err := g.fileSearch(ctx, name, out)
+			if err != nil {
+				g.errs.Report(err)
+			}
+
+			mu.Lock()
+			matchesCount += n
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+
+	return matchesCount
+}
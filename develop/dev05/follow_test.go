@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Дописывает строку s в конец файла с именем name.
+func appendFile(t *testing.T, name, s string) {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowReader(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(name, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := newFollowReader(ctx, f, name, 5*time.Millisecond)
+	defer r.Close()
+	reader := bufio.NewReader(r)
+
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "a\n", line)
+
+	// Дописанные строки читаются после ожидания.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		appendFile(t, name, "b\n")
+	}()
+	line, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "b\n", line)
+
+	// После усечения файл читается с начала, а поиск начинается заново.
+	if err := os.Truncate(name, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, name, "c\n")
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.True(t, r.restarted())
+	line, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "c\n", line)
+	assert.False(t, r.restarted())
+
+	// Усечение определяется и тогда, когда после него в файл записано больше данных, чем было прочитано
+	// (copytruncate): размер файла не уменьшился, но изменилось его начало.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		if err := os.WriteFile(name, []byte("copytruncate\n"), 0644); err != nil {
+			t.Error(err)
+		}
+	}()
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.True(t, r.restarted())
+	line, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "copytruncate\n", line)
+
+	// После ротации читается новый файл.
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte("d\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.True(t, r.restarted())
+	line, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "d\n", line)
+
+	// После отмены контекста чтение завершается.
+	cancel()
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// Ждет, пока в w не будут записаны данные expected, и возвращает записанные данные.
+func waitOutput(t *testing.T, w *lockedWriter, expected string) string {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w.Lock()
+		out := w.w.(*bytes.Buffer).String()
+		w.Unlock()
+		if out == expected || time.Now().After(deadline) {
+			return out
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGrep_followFiles(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(name, []byte("info\nerror one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &lockedWriter{w: new(bytes.Buffer)}
+	var stderr bytes.Buffer
+	g, err := NewGrep([]string{"--follow", "-n", "-b", "error", name}, strings.NewReader(""), out, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() {
+		done <- g.Search(ctx)
+	}()
+
+	expected := "2: 5: error one\n"
+	assert.Equal(t, expected, waitOutput(t, out, expected))
+
+	// После ротации номера строк и смещения отсчитываются от начала нового файла.
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte("error two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected += "1: 0: error two\n"
+	assert.Equal(t, expected, waitOutput(t, out, expected))
+
+	// После copytruncate файл читается с начала, даже если новые данные длиннее прочитанных.
+	if err := os.WriteFile(name, []byte("debug\ninfo\nerror three\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected += "3: 11: error three\n"
+	assert.Equal(t, expected, waitOutput(t, out, expected))
+
+	cancel()
+	assert.Equal(t, 0, <-done)
+	assert.Empty(t, stderr.String())
+}
+
+func TestGrep_followFilesDecompress(t *testing.T) {
+	// Короткий несжатый файл с флагом -z не ожидает, пока в нем наберется блок tar-архива.
+	name := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(name, []byte("error one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &lockedWriter{w: new(bytes.Buffer)}
+	var stderr bytes.Buffer
+	g, err := NewGrep([]string{"--follow", "-z", "error", name}, strings.NewReader(""), out, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() {
+		done <- g.Search(ctx)
+	}()
+
+	expected := "error one\n"
+	assert.Equal(t, expected, waitOutput(t, out, expected))
+
+	appendFile(t, name, "error two\n")
+	expected += "error two\n"
+	assert.Equal(t, expected, waitOutput(t, out, expected))
+
+	cancel()
+	assert.Equal(t, 0, <-done)
+	assert.Empty(t, stderr.String())
+}
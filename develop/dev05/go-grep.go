@@ -1,112 +1,88 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"sort"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
-)
 
-// Коды цветов текста, выводимого в консоли.
-const (
-	redColor     = "\033[31m"
-	greenColor   = "\033[32m"
-	regularColor = "\033[0m"
+	"github.com/hurstcain/tasks_l2/develop/dev05/grep"
 )
 
-// Структура, хранящая информацию о строках, записанных в stdin до совпадения.
-// index - индекс строки.
-// s - строка.
-type beforeData struct {
-	index int
-	s     string
-}
-
-// Конструктор структуры beforeData.
-func newBeforeData(index int, s string) beforeData {
-	return beforeData{
-		index: index,
-		s:     s,
-	}
-}
-
-// Структура, описывающая канал, в который записываются строки, переданные в stdin до совпадения.
-// ch - канал, куда записываются данные.
-// chCapacity - емкость канала.
-type beforeDataChannel struct {
-	ch         chan beforeData
-	chCapacity int
-}
-
-// Конструктор структуры beforeDataChannel.
-func newBeforeDataChannel(chanCapacity int) beforeDataChannel {
-	return beforeDataChannel{
-		ch:         make(chan beforeData, chanCapacity),
-		chCapacity: chanCapacity,
-	}
-}
-
-// WriteBeforeData - записывает данные в канал.
-func (c *beforeDataChannel) WriteBeforeData(index int, s string) {
-	// Если длина канал сравнялась с емкостью, то освобождаем канал от одной записи,
-	// чтобы добавить актуальные данные.
-	if len(c.ch) == c.chCapacity {
-		<-c.ch
-	}
-	// Записываем в канал новые данные.
-	c.ch <- newBeforeData(index, s)
-}
-
-// PrintBeforeData - выводит на экран данные, которые были записаны в stdin до совпадения.
-// lineNum - флаг, который определяет, нужно ли выводить индекс строки или нет.
-func (c *beforeDataChannel) PrintBeforeData(lineNum bool) {
-	// Длина канала.
-	chLen := len(c.ch)
-
-	// Читаем все данные, которые были записаны в канал, и выводим их построчно на экран.
-	for i := 0; i < chLen; i++ {
-		data := <-c.ch
-		if lineNum {
-			fmt.Printf("%s%d: %s%s\n", greenColor, data.index, regularColor, data.s)
-		} else {
-			fmt.Print(lineNum, regularColor, data.s, "\n")
-		}
-	}
-}
+// Имя, под которым выводится стандартный ввод.
+const stdinName = "(standard input)"
 
 // Структура, в которой хранятся ключи, переданные при запуске программы.
 type grepFlags struct {
-	// Паттерн для поиска совпадений.
-	Pattern string
-	// Название файла.
-	FileName string
-	// Количество строк после совпадения.
-	After int
-	// Количество строк до совпадения.
-	Before int
+	// Параметры поиска совпадений в строках: паттерны, режим их интерпретации (флаги -G, -E, -P, -F),
+	// флаги -i, -w, -x, -v, количество строк до и после совпадения, максимальное количество совпадений,
+	// кодировка данных (флаг --encoding) и разделение строк нулевым байтом (флаг --null-data).
+	grep.Options
+	// Названия файлов и директорий для поиска.
+	FileNames []string
+	// Рекурсивный обход директорий (флаги -r и -R).
+	Recursive bool
+	// Разыменовывать символические ссылки при рекурсивном обходе (флаг -R).
+	Dereference bool
+	// Шаблоны имен файлов, в которых нужно искать совпадения.
+	Include []string
+	// Шаблоны имен файлов, которые нужно пропустить.
+	Exclude []string
+	// Шаблоны имен директорий, которые нужно пропустить при рекурсивном обходе.
+	ExcludeDir []string
+	// Выводить только имена файлов, в которых есть совпадения.
+	FilesWithMatches bool
+	// Выводить только имена файлов, в которых нет совпадений.
+	FilesWithoutMatch bool
+	// Выводить имя файла перед каждой строкой.
+	WithFileName bool
+	// Не выводить имя файла перед строками (флаг -h).
+	NoFileName bool
+	// Распаковывать сжатые файлы и искать в файлах tar-архивов (флаг -z).
+	Decompress bool
+	// Выводить результаты в формате JSON, по одному событию на строку (флаг --json).
+	JSON bool
+	// Продолжать поиск в файлах после их конца, ожидая новые строки (флаг --follow).
+	Follow bool
+	// Количество файлов, в которых поиск происходит одновременно.
+	Jobs int
+	// Выводить только совпадающие части строк.
+	OnlyMatching bool
+	// Выводить смещение в байтах перед каждой строкой.
+	ByteOffset bool
+	// Цвета, которыми подсвечивается вывод.
+	Colors colorScheme
+	// Ничего не выводить и прекратить поиск после первого совпадения.
+	Quiet bool
+	// Не выводить сообщения об ошибках чтения файлов.
+	NoMessages bool
+	// Указан флаг -m 0: данные не читаются, ни одна строка не выбирается.
+	ZeroMaxCount bool
 	// Вывод количества строк, которые соответствуют паттерну.
 	Count bool
-	// Флаг игнорирования регистра.
-	IgnoreCase bool
-	// Флаг, обозначающий исключение вместо совпадения.
-	Invert bool
-	// Флаг, определяющий поиск точного совпадения с введенной строкой.
-	Fixed bool
 	// Флаг, определяющий печатать номер строки или нет.
 	LineNum bool
 }
 
-// Парсит флаги и инициализирует структуру grepFlags.
-func newGrepFlags() grepFlags {
+// Парсит флаги из аргументов командной строки args (без имени программы) и инициализирует структуру grepFlags.
+// Из stdin читаются паттерны при флаге -f -. От того, является ли stdout терминалом, зависит подсветка
+// при --color=auto. Сообщения об ошибках разбора флагов и справка выводятся в stderr.
+func newGrepFlags(args []string, stdin io.Reader, stdout, stderr io.Writer) (grepFlags, error) {
+	fs := flag.NewFlagSet("go-grep", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
 	// Количество строк после совпадения.
 	var after int
 	// Количество строк до совпадения.
 	var before int
-	flag.Func("A", "Печатать n строк после совпадения", func(aValue string) error {
+	fs.Func("A", "Печатать n строк после совпадения", func(aValue string) error {
 		aInt, err := strconv.Atoi(aValue)
 		if err != nil {
 			return err
@@ -116,7 +92,7 @@ func newGrepFlags() grepFlags {
 		}
 		return nil
 	})
-	flag.Func("B", "Печатать n строк до совпадения", func(bValue string) error {
+	fs.Func("B", "Печатать n строк до совпадения", func(bValue string) error {
 		bInt, err := strconv.Atoi(bValue)
 		if err != nil {
 			return err
@@ -126,7 +102,7 @@ func newGrepFlags() grepFlags {
 		}
 		return nil
 	})
-	flag.Func("C", "Печатать n строк вокруг совпадения", func(cValue string) error {
+	fs.Func("C", "Печатать n строк вокруг совпадения", func(cValue string) error {
 		cInt, err := strconv.Atoi(cValue)
 		if err != nil {
 			return err
@@ -137,344 +113,489 @@ func newGrepFlags() grepFlags {
 		}
 		return nil
 	})
-	count := flag.Bool("c", false, "Вывод количества строк")
-	ignoreCase := flag.Bool("i", false, "Игнорировать регистр")
-	invert := flag.Bool("v", false, "Вместо совпадения исключать")
-	fixed := flag.Bool("F", false, "Точное совпадение со строкой, не паттерн")
-	lineNum := flag.Bool("n", false, "Напечатать номер строки")
-	flag.Parse()
+	// Паттерны, переданные с помощью флагов -e и -f.
+	var patterns []string
+	patternsSet := false
+	fs.Func("e", "Паттерн для поиска совпадений, флаг может быть указан несколько раз", func(eValue string) error {
+		patterns = append(patterns, strings.Split(eValue, "\n")...)
+		patternsSet = true
+		return nil
+	})
+	fs.Func("f", "Читать паттерны из файла, по одному на строку", func(fValue string) error {
+		filePatterns, err := readPatterns(fValue, stdin)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, filePatterns...)
+		patternsSet = true
+		return nil
+	})
+	wordRegexp := fs.Bool("w", false, "Искать совпадения только с целыми словами")
+	lineRegexp := fs.Bool("x", false, "Искать совпадения только с целыми строками")
+	count := fs.Bool("c", false, "Вывод количества строк")
+	ignoreCase := fs.Bool("i", false, "Игнорировать регистр")
+	invert := fs.Bool("v", false, "Вместо совпадения исключать")
+	basic := fs.Bool("G", false, "Паттерн - базовое регулярное выражение POSIX (по умолчанию)")
+	extended := fs.Bool("E", false, "Паттерн - расширенное регулярное выражение POSIX")
+	perl := fs.Bool("P", false, "Паттерн - регулярное выражение в синтаксисе Go RE2")
+	fixed := fs.Bool("F", false, "Паттерн - фиксированная строка, а не регулярное выражение")
+	lineNum := fs.Bool("n", false, "Напечатать номер строки")
+	recursive := fs.Bool("r", false, "Рекурсивный поиск в директориях")
+	dereference := fs.Bool("R", false, "Рекурсивный поиск в директориях с переходом по символическим ссылкам")
+	// Шаблоны имен файлов и директорий.
+	var include, exclude, excludeDir []string
+	fs.Func("include", "Искать только в файлах, имена которых соответствуют шаблону", globFlag(&include))
+	fs.Func("exclude", "Пропускать файлы, имена которых соответствуют шаблону", globFlag(&exclude))
+	fs.Func("exclude-dir", "Пропускать директории, имена которых соответствуют шаблону", globFlag(&excludeDir))
+	filesWithMatches := fs.Bool("l", false, "Вывести только имена файлов, в которых есть совпадения")
+	filesWithoutMatch := fs.Bool("L", false, "Вывести только имена файлов, в которых нет совпадений")
+	withFileName := fs.Bool("H", false, "Печатать имя файла для каждого совпадения")
+	noFileName := fs.Bool("h", false, "Не печатать имена файлов")
+	onlyMatching := fs.Bool("o", false, "Выводить только совпадающие части строк")
+	byteOffset := fs.Bool("b", false, "Выводить смещение в байтах перед каждой строкой")
+	jsonOutput := fs.Bool("json", false, "Выводить результаты в формате JSON, по одному событию на строку")
+	follow := fs.Bool("follow", false, "Не завершать поиск в конце файлов, а ждать новые строки, как tail -F")
+	encoding := fs.String("encoding", "", "Кодировка файлов: utf-8, utf-16, utf-16le, utf-16be, windows-1251 или koi8-r (по умолчанию определяется по BOM)")
+	nullData := fs.Bool("null-data", false, "Строки во входных данных и в выводе разделяются нулевым байтом, а не переводом строки")
+	decompress := fs.Bool("z", false, "Распаковывать файлы, сжатые gzip, bzip2 и zstd, и искать в файлах tar-архивов")
+	color := colorFlag(colorAuto)
+	fs.Var(&color, "color", "Подсветка совпадений: auto, always или never")
+	quiet := fs.Bool("q", false, "Ничего не выводить, завершить работу после первого совпадения")
+	noMessages := fs.Bool("s", false, "Не выводить сообщения об ошибках чтения файлов")
+	maxCount := fs.Int("m", -1, "Прекратить поиск в файле после n совпадений")
+	jobs := fs.Int("j", 1, fmt.Sprintf("Количество файлов, в которых поиск происходит одновременно (0 - по числу ядер, %d)", runtime.NumCPU()))
+	if err := fs.Parse(args); err != nil {
+		return grepFlags{}, errUsage
+	}
 
-	// Строка-паттерн для поиска совпадений. Паттерном для поиска считается первый аргумент не флаг.
-	pattern := flag.Arg(0)
-	if pattern == "" {
-		log.Fatalln("Не был введен паттерн для поиска совпадений.")
+	// Режим поиска. Одновременно может быть указан только один из флагов -G, -E, -P, -F.
+	mode := grep.BasicRegexp
+	modesCount := 0
+	for m, isSet := range map[grep.Mode]bool{
+		grep.BasicRegexp:    *basic,
+		grep.ExtendedRegexp: *extended,
+		grep.PerlRegexp:     *perl,
+		grep.FixedString:    *fixed,
+	} {
+		if isSet {
+			mode = m
+			modesCount++
+		}
+	}
+	if modesCount > 1 {
+		return grepFlags{}, errors.New("флаги -G, -E, -P и -F не могут быть указаны одновременно")
+	}
+
+	// Строка-паттерн для поиска совпадений. Если паттерны не переданы с помощью флагов -e и -f,
+	// то паттерном для поиска считается первый аргумент не флаг.
+	// Паттерн, содержащий переводы строк, считается несколькими паттернами.
+	fileNames := fs.Args()
+	if !patternsSet {
+		if fs.NArg() == 0 {
+			return grepFlags{}, errors.New("не был введен паттерн для поиска совпадений")
+		}
+		patterns = strings.Split(fs.Arg(0), "\n")
+		fileNames = fileNames[1:]
+	}
+	// Названия файлов. Поиск совпадений в файлах опционален. Названиями файлов считаются все аргументы после паттерна.
+	// Если файлы не указаны, то поиск происходит в stdin (имя файла "-"),
+	// а при рекурсивном поиске - в текущей директории.
+	if len(fileNames) == 0 {
+		fileNames = []string{"-"}
+		if *recursive || *dereference {
+			fileNames = []string{"."}
+		}
+	}
+
+	f, ok := stdout.(*os.File)
+	colors, err := newColorScheme(string(color), ok && isTerminal(f), os.Getenv("GREP_COLORS"))
+	if err != nil {
+		return grepFlags{}, err
+	}
+
+	// При выводе только совпадающих частей строк строки до и после совпадения не выводятся.
+	if *onlyMatching {
+		after = 0
+		before = 0
+	}
+
+	// В формате JSON выводятся только найденные строки.
+	if *jsonOutput && (*count || *filesWithMatches || *filesWithoutMatch || *onlyMatching) {
+		return grepFlags{}, errors.New("флаг --json не может быть указан вместе с флагами -c, -l, -L и -o")
+	}
+
+	// При флаге --follow поиск происходит только в переданных файлах.
+	if *follow && (*recursive || *dereference) {
+		return grepFlags{}, errors.New("флаг --follow не может быть указан вместе с флагами -r и -R")
+	}
+
+	if *jobs < 0 {
+		return grepFlags{}, errors.New("значение флага -j не может быть отрицательным")
+	}
+	if *jobs == 0 {
+		*jobs = runtime.NumCPU()
+	}
+
+	// По умолчанию имя файла печатается, если поиск происходит в нескольких файлах.
+	printFileName := len(fileNames) > 1 || *recursive || *dereference
+	switch {
+	case *withFileName:
+		printFileName = true
+	case *noFileName:
+		printFileName = false
 	}
-	// Название файла. Поиск совпадений в файле опционален. Названием файла считается второй аргумент.
-	fileName := flag.Arg(1)
 
 	return grepFlags{
-		Pattern:    pattern,
-		FileName:   fileName,
-		After:      after,
-		Before:     before,
-		Count:      *count,
-		IgnoreCase: *ignoreCase,
-		Invert:     *invert,
-		Fixed:      *fixed,
-		LineNum:    *lineNum,
+		Options: grep.Options{
+			Patterns:   patterns,
+			Mode:       mode,
+			IgnoreCase: *ignoreCase,
+			WordRegexp: *wordRegexp,
+			LineRegexp: *lineRegexp,
+			Invert:     *invert,
+			Before:     before,
+			After:      after,
+			MaxCount:   *maxCount,
+			Encoding:   *encoding,
+			NullData:   *nullData,
+		},
+		FileNames:         fileNames,
+		Recursive:         *recursive || *dereference,
+		Dereference:       *dereference,
+		Include:           include,
+		Exclude:           exclude,
+		ExcludeDir:        excludeDir,
+		FilesWithMatches:  *filesWithMatches,
+		FilesWithoutMatch: *filesWithoutMatch,
+		WithFileName:      printFileName,
+		NoFileName:        *noFileName,
+		Decompress:        *decompress,
+		JSON:              *jsonOutput,
+		Follow:            *follow,
+		Jobs:              *jobs,
+		OnlyMatching:      *onlyMatching,
+		ByteOffset:        *byteOffset,
+		Colors:            colors,
+		Quiet:             *quiet,
+		NoMessages:        *noMessages,
+		ZeroMaxCount:      *maxCount == 0,
+		Count:             *count,
+		LineNum:           *lineNum,
+	}, nil
+}
+
+// Читает паттерны из файла с именем name, по одному паттерну на строку.
+// Если name == "-", то паттерны читаются из stdin. Пустой файл не содержит ни одного паттерна,
+// а пустая строка в файле - пустой паттерн, которому соответствует любая строка.
+func readPatterns(name string, stdin io.Reader) ([]string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// Возвращает функцию для flag.Func, которая проверяет шаблон имени файла и добавляет его в слайс globs.
+func globFlag(globs *[]string) func(string) error {
+	return func(pattern string) error {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return err
+		}
+		*globs = append(*globs, pattern)
+		return nil
 	}
 }
 
 // Grep - структура, реализующая функционал линуксовой утилиты grep.
 type Grep struct {
-	// Слайс, содержащий строки исходного файла. Используется, когда поиск совпадений осуществляется в файле.
-	fileContent []string
 	// Количество совпадений.
 	matchesCount int
-	flags        grepFlags
+	// Ищет совпадения в строках.
+	searcher *grep.Searcher
+	// Выводит сообщения об ошибках и запоминает, что ошибка произошла.
+	errs *errorLog
+	// Стандартный ввод, в котором происходит поиск, если указано имя файла "-".
+	stdin io.Reader
+	// Поток, в который выводятся результаты.
+	stdout io.Writer
+	flags  grepFlags
 }
 
 // NewGrep - конструктор структуры Grep.
-// Инициализирует переданные при запуске программы ключи и считывает данные из исходного файла,
-// если он указан.
-func NewGrep() Grep {
-	flags := newGrepFlags()
-
-	// Если название файла - пустая строка, то поиск будет происходить во время ввода данных в stdin.
-	if flags.FileName == "" {
-		return Grep{
-			matchesCount: 0,
-			flags:        flags,
-		}
-	}
-
-	// Открываем файл с именем fileName.
-	f, err := os.Open(flags.FileName)
+// Инициализирует ключи и паттерн для поиска из аргументов командной строки args (без имени программы).
+// Поиск в stdin происходит в данных из stdin, результаты выводятся в stdout, ошибки - в stderr.
+func NewGrep(args []string, stdin io.Reader, stdout, stderr io.Writer) (Grep, error) {
+	flags, err := newGrepFlags(args, stdin, stdout, stderr)
 	if err != nil {
-		log.Fatalf("Не удалось открыть исходный файл. Ошибка: %v\n", err)
+		return Grep{}, err
 	}
-	// После завершения работы функции файл закрывается.
-	defer f.Close()
-
-	// Слайс, в который будут записаны строки из файла.
-	fileContent := make([]string, 0)
 
-	// Построчно читаем данные из файла.
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		fileContent = append(fileContent, scanner.Text())
+	s, err := grep.New(flags.Options)
+	if err != nil {
+		return Grep{}, err
 	}
 
 	return Grep{
-		fileContent:  fileContent,
 		matchesCount: 0,
+		searcher:     s,
+		errs:         newErrorLog(stderr, flags.NoMessages),
+		stdin:        stdin,
+		stdout:       stdout,
 		flags:        flags,
-	}
+	}, nil
 }
 
-// Search - определяет, где будет осуществляться поиск совпадений (в файле или в stdin),
-// и вызывает соответствующий метод.
-func (g Grep) Search() {
-	// Если название файла - пустая строка, то поиск происходит в stdin,
-	// иначе - в файле.
-	if g.flags.FileName == "" {
-		g.stdinSearch()
-	} else {
-		g.fileSearch()
+// Search - осуществляет поиск совпадений во всех файлах, переданных при запуске программы,
+// или в stdin, если файлы не указаны.
+// Возвращает код завершения программы: exitMatch, если найдена хотя бы одна строка,
+// exitNoMatch, если не найдено ни одной строки, и exitError, если произошла ошибка.
+// При флаге -q найденное совпадение важнее ошибки.
+func (g Grep) Search(ctx context.Context) int {
+	w := g.stdout
+	if g.flags.Quiet {
+		w = io.Discard
+	}
+
+	g.matchesCount = g.searchFiles(ctx, w)
+
+	switch {
+	case g.flags.Quiet && g.matchesCount > 0:
+		return exitMatch
+	case g.errs.Failed():
+		return exitError
+	case g.matchesCount > 0:
+		return exitMatch
+	default:
+		return exitNoMatch
 	}
 }
 
-// Метод, осуществляющий поиск совпадений в файле.
-func (g *Grep) fileSearch() {
-	// Мапа, в которую записываются индексы строк для вывода (строки-совпадения и строки до и после совпадения).
-	indexesSet := make(map[int]struct{})
-	// Количество строк в файле.
-	fileContentLen := len(g.fileContent)
-
-	for i, val := range g.fileContent {
-		// Строка для сравнения.
-		str := val
-		// Паттерн, с которым сравнивается строка.
-		pattern := g.flags.Pattern
-
-		// Если при запуске программы указан флаг игнорирования регистра, то приводим строку
-		// и паттерн к нижнему регистру.
-		if g.flags.IgnoreCase {
-			str = strings.ToLower(str)
-			pattern = strings.ToLower(pattern)
-		}
+// Метод, осуществляющий поиск совпадений в файле с именем name. Результат записывается в w.
+// Если name - "-", то поиск осуществляется в stdin.
+// Возвращает количество совпадений в файле.
+// Метод не изменяет g, поэтому может одновременно вызываться из нескольких горутин.
+func (g Grep) fileSearch(ctx context.Context, name string, w io.Writer) (int, error) {
+	search := g.readerSearch
+	if g.flags.Decompress {
+		search = g.archiveSearch
+	}
 
-		// Поиск совпадений.
-		switch {
-		case g.flags.Invert:
-			// Если при запуске указан флаг исключения, то результатом будут строки, которые не являются совпадением.
-			if !g.compare(str, pattern) {
-				indexesSet[i] = struct{}{}
-				// Увеличиваем счетчик количества совпадений на единицу.
-				g.matchesCount++
-			}
-		default:
-			// Если строка является совпадением.
-			if g.compare(str, pattern) {
-				// Записываем индекс строки в результат.
-				indexesSet[i] = struct{}{}
-
-				// Записываем в результат индексы g.flags.After строк после совпадения.
-				for j := i + 1; j < fileContentLen && j <= i+g.flags.After; j++ {
-					indexesSet[j] = struct{}{}
-				}
-
-				// Записываем в результат индексы g.flags.Before строк до совпадения.
-				for j := i - g.flags.Before; j < i; j++ {
-					if j < 0 {
-						continue
-					}
-					indexesSet[j] = struct{}{}
-				}
-
-				g.matchesCount++
-			}
-		}
+	if name == "-" {
+		return search(ctx, stdinName, g.stdin, w)
 	}
 
-	// Если указан флаг вывода количества совпадений, то выводим их на экран и завершаем работу функции.
-	if g.flags.Count {
-		g.printMatchesCount()
-		return
+	// Открываем файл с именем name.
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось открыть исходный файл. Ошибка: %v", err)
+	}
+
+	// При флаге --follow файл читается и после его конца.
+	if g.flags.Follow {
+		return g.followSearch(ctx, name, newFollowReader(ctx, f, name, followInterval), w, search)
 	}
+	// После завершения работы функции файл закрывается.
+	defer f.Close()
 
-	g.printStrings(g.createOrderedSet(indexesSet))
+	return search(ctx, name, f, w)
 }
 
-// Метод, сравнивающий строку и паттерн.
-func (g Grep) compare(str, pattern string) bool {
-	// Если указан флаг точного совпадения с паттерном, то возвращаем результат сравнения паттерна со строкой.
-	if g.flags.Fixed {
-		return str == pattern
+// Метод, осуществляющий поиск совпадений в данных из r. name - имя, под которым выводятся строки из r.
+// Результат записывается в w. Возвращает количество совпадений.
+// Строки выводятся сразу после того, как они найдены, поэтому поиск одинаково работает
+// и в файлах, и в данных, вводимых в stdin.
+func (g Grep) readerSearch(ctx context.Context, name string, r io.Reader, w io.Writer) (int, error) {
+	// При флаге -m 0 данные не читаются.
+	if g.flags.ZeroMaxCount {
+		r = strings.NewReader("")
 	}
-	// Иначе проверяем, содержит ли строка паттерн, и возвращаем результат.
-	return strings.Contains(str, pattern)
-}
 
-// Из неупорядоченной мапы с индексами строк для вывода (indexesSet)
-// создает отсортированный по возрастанию индексов слайс,
-// который будет использоваться для вывода строк.
-func (g Grep) createOrderedSet(indexesSet map[int]struct{}) []int {
-	// Упорядоченное множество индексов строк для вывода.
-	orderedSet := make([]int, len(indexesSet))
-	i := 0
+	matches := g.searcher.Search(ctx, r)
+	// Для бинарных данных вместо строк-совпадений выводится только сообщение о том, что совпадение найдено.
+	binary := matches.Binary()
+	// Нужно ли выводить строки.
+	printLines := !g.flags.Count && !g.flags.FilesWithMatches && !g.flags.FilesWithoutMatch && !binary && !g.flags.Quiet
+	// Выводятся ли строки до и после совпадений. Несмежные группы таких строк разделяются строкой "--".
+	withContext := g.flags.Before > 0 || g.flags.After > 0
 
-	// Проходимся по всем индексам в мапе и записываем их в слайс.
-	for index := range indexesSet {
-		orderedSet[i] = index
-		i++
+	// Количество совпадений.
+	matchesCount := 0
+	// Номер последней выведенной строки.
+	lastPrinted := 0
+	// При флаге --json строки выводятся как события в формате JSON.
+	var jw *jsonWriter
+	if g.flags.JSON {
+		jw = newJSONWriter(w, name)
 	}
 
-	// Сортируем слайс по возрастанию.
-	sort.Ints(orderedSet)
+	for matches.Next() {
+		m := matches.Match()
 
-	return orderedSet
-}
+		if !m.Context {
+			// Увеличиваем счетчик количества совпадений на единицу.
+			matchesCount++
+			// При флаге --follow количество совпадений выводится после каждого нового совпадения.
+			if g.flags.Count && g.flags.Follow {
+				fmt.Fprint(w, g.fileNamePrefix(name), matchesCount, "\n")
+			}
 
-// Выводит на экран строки-совпадения, а также строки до и после совпадений, если указаны соответствующие флаги.
-func (g Grep) printStrings(orderedSet []int) {
-	// Проходимся по всем индексам строк для вывода.
-	for _, index := range orderedSet {
-		// Строка для вывода.
-		str := g.fileContent[index]
-		// Паттерн для сравнения.
-		pattern := g.flags.Pattern
-
-		// Если указан флаг игнорирования регистра, то приводим строку и паттерн к нижнему регистру.
-		if g.flags.IgnoreCase {
-			str = strings.ToLower(str)
-			pattern = strings.ToLower(pattern)
+			// Для вывода имени файла или сообщения о бинарных данных достаточно первого совпадения.
+			// При флаге -q поиск также прекращается после первого совпадения.
+			if g.flags.Quiet || g.flags.FilesWithMatches || g.flags.FilesWithoutMatch || (binary && !g.flags.Count) {
+				break
+			}
+		}
+		if !printLines {
+			continue
+		}
+		if jw != nil {
+			jw.Write(m)
+			continue
 		}
 
-		// Строка с индексом выводимой строки.
-		// Подсвечивается в консоли зеленым.
-		// Непустая только в том случае, если указан флаг вывода номера строки.
-		var lineNum string
-		if g.flags.LineNum {
-			lineNum = fmt.Sprintf("%s%d: ", greenColor, index+1)
+		// Если строка не следует сразу за последней выведенной, то сначала выводится разделитель групп.
+		if withContext && lastPrinted > 0 && m.LineNum > lastPrinted+1 {
+			fmt.Fprint(w, g.flags.Colors.paint(g.flags.Colors.separator, "--"), "\n")
 		}
+		g.printString(w, name, m)
+		lastPrinted = m.LineNum
+	}
 
-		// Если строка является строкой-совпадением, то она подсвечивается красным.
-		if g.compare(str, pattern) {
-			fmt.Print(lineNum, redColor, g.fileContent[index], "\n")
-			continue
+	switch {
+	case g.flags.Quiet:
+	case jw != nil:
+		jw.End(binary, matchesCount)
+	case g.flags.FilesWithMatches:
+		if matchesCount > 0 {
+			g.printFileName(w, name)
+		}
+	case g.flags.FilesWithoutMatch:
+		if matchesCount == 0 {
+			g.printFileName(w, name)
 		}
+	case g.flags.Count:
+		// Если указан флаг вывода количества совпадений, то выводим их на экран.
+		// При флаге --follow количество уже выведено после последнего совпадения.
+		if !g.flags.Follow {
+			fmt.Fprint(w, g.fileNamePrefix(name), matchesCount, "\n")
+		}
+	case binary && matchesCount > 0:
+		fmt.Fprintf(w, "Binary file %s matches\n", name)
+	}
 
-		// Строки, которые не являются совпадениями, выводятся в обычном цвете.
-		fmt.Print(lineNum, regularColor, g.fileContent[index], "\n")
+	// При флаге --follow поиск завершается отменой контекста, это не является ошибкой.
+	if err := matches.Err(); err != nil && !(g.flags.Follow && errors.Is(err, context.Canceled)) {
+		return matchesCount, fmt.Errorf("ошибка при чтении %s: %v", name, err)
 	}
 
-	// Устанавливаем в консоли обычный цвет шрифта.
-	fmt.Print(regularColor)
+	return matchesCount, nil
 }
 
-// Выводит на экран количество строк-совпадений.
-func (g Grep) printMatchesCount() {
-	fmt.Println(g.matchesCount)
+// Возвращает префикс с именем файла name, который выводится перед строками файла.
+// Непустой только в том случае, если имя файла нужно выводить.
+func (g Grep) fileNamePrefix(name string) string {
+	if !g.flags.WithFileName {
+		return ""
+	}
+
+	return g.flags.Colors.paint(g.flags.Colors.fileName, name) + g.separator()
 }
 
-// Метод, осуществляющий поиск совпадений во время ввода данных в stdin.
-func (g *Grep) stdinSearch() {
-	reader := bufio.NewReader(os.Stdin)
-	// Номер текущей строки.
-	index := 1
-	// Канал, в который записываются строки до совпадения.
-	chBeforeData := newBeforeDataChannel(g.flags.Before)
-	// Количество строк после совпадения, которые нужно вывести.
-	afterCount := 0
-
-	for {
-		// s - введенная строка.
-		s, err := reader.ReadString('\n')
-		if err != nil {
-			log.Fatalf("Ошибка при чтении из stdin: %v", err)
-		}
-		// Слайс рун, в который будут скопированы руны из строки s, но без последних двух символов (10 и 13).
-		sRunes := make([]rune, len([]rune(s))-2)
-		copy(sRunes, []rune(s))
-		// Присваиваем строке новое значение без лишних символов в конце строки.
-		s = string(sRunes)
-
-		if s != "" && s[0] == 4 && len(s) == 1 {
-			break
-		}
+// Возвращает разделитель между префиксами и строкой.
+func (g Grep) separator() string {
+	return g.flags.Colors.paint(g.flags.Colors.separator, ":") + " "
+}
 
-		// Версия ввода данных для Linux:
-		// // s - введенная строка.
-		//	s, err := reader.ReadString('\n')
-		//	if err != nil {
-		//		// Если были нажаты ctrl+D, то ввод данных прекращается и программа завершает работу.
-		//		if err == io.EOF {
-		//			return
-		//		}
-		//		log.Fatalf("Ошибка при чтении из stdin: %v", err)
-		//	}
-		//	// Слайс рун, в который будут скопированы руны из строки s, но без символа переноса строки (10).
-		//	sRunes := make([]rune, len([]rune(s))-1)
-		//	copy(sRunes, []rune(s))
-		//	// Присваиваем строке новое значение без лишних символов в конце строки.
-		//	s = string(sRunes)
-
-		// Строка для сравнения.
-		str := s
-		// Паттерн.
-		pattern := g.flags.Pattern
-
-		// Если указан флаг игнорирования регистра, то приводим строку и паттерн к нижнему регистру.
-		if g.flags.IgnoreCase {
-			str = strings.ToLower(str)
-			pattern = strings.ToLower(pattern)
-		}
+// Возвращает префикс строки: имя файла, номер строки index и смещение offset,
+// если указаны соответствующие флаги.
+func (g Grep) linePrefix(name string, index int, offset int64) string {
+	c := g.flags.Colors
+	prefix := g.fileNamePrefix(name)
 
-		// Строка с индексом выводимой строки.
-		// Подсвечивается в консоли зеленым.
-		// Непустая только в том случае, если указан флаг вывода номера строки.
-		var lineNum string
-		if g.flags.LineNum {
-			lineNum = fmt.Sprintf("%s%d: ", greenColor, index)
-		}
+	if g.flags.LineNum {
+		prefix += c.paint(c.lineNum, strconv.Itoa(index)) + g.separator()
+	}
+	if g.flags.ByteOffset {
+		prefix += c.paint(c.byteOffset, strconv.FormatInt(offset, 10)) + g.separator()
+	}
 
-		// Поиск совпадений.
-		switch {
-		case g.flags.Invert:
-			if !g.compare(str, pattern) {
-				// Если при запуске указан флаг исключения,
-				// то результатом будут строки, которые не являются совпадением.
-				// Вывод введенной строки на экран.
-				fmt.Print(lineNum, regularColor, s, "\n")
-				// Увеличиваем счетчик количества совпадений на единицу.
-				g.matchesCount++
-			}
-		default:
-			// Если строка является совпадением.
-			if g.compare(str, pattern) {
-				// Вывод на экран данных, которые были введены до совпадения.
-				chBeforeData.PrintBeforeData(g.flags.LineNum)
-
-				// Вывод на экран строки-совпадения, введенной раннее.
-				fmt.Print(lineNum, redColor, s, "\n")
-				fmt.Print(regularColor)
-
-				// Количество строк, которые будут выведены после совпадения.
-				// (Строки, введенные после совпадения, сразу выводятся на экран).
-				afterCount = g.flags.After
-				// Увеличиваем счетчик количества совпадений на единицу.
-				g.matchesCount++
-				// Увеличиваем номер текущей строки.
-				index++
-				continue
-			}
-		}
+	return prefix
+}
+
+// Записывает в w имя файла. Используется с флагами -l и -L.
+func (g Grep) printFileName(w io.Writer, name string) {
+	fmt.Fprint(w, g.flags.Colors.paint(g.flags.Colors.fileName, name), "\n")
+}
+
+// Записывает в w найденную строку m из файла name.
+// Совпадения подсвечиваются в выбранных строках, а при флаге -v - в строках контекста.
+// При флаге -o выводятся только совпадающие части выбранных строк, каждая на отдельной строке.
+func (g Grep) printString(w io.Writer, name string, m grep.Match) {
+	c := g.flags.Colors
+	matchColor, lineColor := c.selectedMatch, c.selectedLine
+	if m.Context {
+		matchColor, lineColor = c.contextMatch, c.contextLine
+	}
 
-		// Если есть строки после совпадения для вывода, то выводим только что введенную строку на экран.
-		if afterCount > 0 {
-			fmt.Print(lineNum, regularColor, s, "\n")
-			// Уменьшаем счетчик строк для вывода после совпадения на единицу.
-			afterCount--
-		} else if g.flags.Before > 0 {
-			// Если строк для вывода после совпадения нет и если был указан ненулевой флаг для вывода
-			// строк до совпадения, то записываем текущую строку в канал.
-			chBeforeData.WriteBeforeData(index, s)
+	if g.flags.OnlyMatching {
+		// При флаге -v выбранные строки не содержат совпадений, поэтому выводить нечего.
+		if m.Context {
+			return
+		}
+		for _, span := range m.Spans {
+			fmt.Fprint(w, g.linePrefix(name, m.LineNum, m.Offset+int64(span[0])), c.paint(matchColor, m.Line[span[0]:span[1]]), g.lineEnd())
 		}
+		return
+	}
 
-		// Увеличиваем номер текущей строки.
-		index++
+	line := c.paint(lineColor, m.Line)
+	if c.enabled && len(m.Spans) > 0 {
+		line = c.highlight(m.Line, m.Spans, matchColor, lineColor)
 	}
 
-	// Если указан флаг вывода количества совпадений, то выводим их на экран.
-	if g.flags.Count {
-		g.printMatchesCount()
+	fmt.Fprint(w, g.linePrefix(name, m.LineNum, m.Offset), line, g.lineEnd())
+}
+
+// Возвращает символ, которым завершается каждая выведенная строка: перевод строки
+// или нулевой байт при флаге --null-data.
+func (g Grep) lineEnd() string {
+	if g.flags.NullData {
+		return "\x00"
 	}
+
+	return "\n"
 }
 
 func main() {
-	grep := NewGrep()
-	grep.Search()
+	g, err := NewGrep(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		// Сообщение об ошибке разбора флагов уже выведено пакетом flag.
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "go-grep: %v\n", err)
+		}
+		os.Exit(exitError)
+	}
+
+	// При флаге --follow поиск продолжается до прерывания программы (Ctrl+C).
+	ctx := context.Background()
+	stop := func() {}
+	if g.flags.Follow {
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+	}
+	code := g.Search(ctx)
+	stop()
+
+	os.Exit(code)
 
 	// Примеры работы программы:
 
@@ -510,6 +631,7 @@ func main() {
 	// 2
 	// 2
 	// 3
+	// --
 	// abcd
 	// 12a
 	// 324
@@ -521,6 +643,7 @@ func main() {
 	// 2
 	// 3
 	// 2222
+	// --
 	// 12a
 	// 324
 	// абвгд
@@ -536,6 +659,20 @@ func main() {
 	// abcd
 	// 12a
 
+	// Вывод программы с флагами -v -A 1 -n 2 1:
+	// 1: 1
+	// 2: 2
+	// --
+	// 4: 3
+	// 5: 2222
+	// 6: abcd
+	// 7: 12a
+	// --
+	// 9: абвгд
+	// 10:
+	// 11: 34
+	// 12: 2
+
 	// Вывод программы с флагами -v 2 1:
 	// 1
 	// 3
@@ -547,17 +684,116 @@ func main() {
 	// Вывод программы с флагами -F 2 1:
 	// 2
 	// 2
+	// 2222
+	// 12a
+	// 324
 	// 2
 
-	// Вывод программы с флагами -C 1 -F -n 2 1:
+	// Вывод программы с флагами -C 1 -E -n ^(1|3)$ 1:
 	// 1: 1
 	// 2: 2
 	// 3: 2
 	// 4: 3
-	// 11: 34
+	// 5: 2222
+
+	// Вывод программы с флагами -i -P -n ^A\w+ 1:
+	// 6: abcd
+
+	// Вывод программы с флагами 2\{4\} 1:
+	// 2222
+
+	// Вывод программы с флагами -E -o -b 2+ 1:
+	// 2: 2
+	// 4: 2
+	// 8: 2222
+	// 19: 2
+	// 23: 2
+	// 41: 2
+
+	// Вывод программы с флагами -w -n 2 1:
+	// 2: 2
+	// 3: 2
 	// 12: 2
 
-	// 2) Поиск совпадений при вводе данных в stdin
+	// Вывод программы с флагами -x 2 1:
+	// 2
+	// 2
+	// 2
+
+	// Вывод программы с флагами -e 34 -e abcd -n 1:
+	// 6: abcd
+	// 11: 34
+
+	// Вывод программы с флагами -c -f patterns.txt 1, где файл patterns.txt содержит строки 2 и abcd:
+	// 7
+
+	// Вывод программы с флагами --json -A 1 -w 3 1:
+	// {"type":"begin","data":{"path":{"text":"1"}}}
+	// {"type":"match","data":{"path":{"text":"1"},"line":{"text":"3"},"line_number":4,"absolute_offset":6,"submatches":[{"match":{"text":"3"},"start":0,"end":1}]}}
+	// {"type":"context","data":{"path":{"text":"1"},"line":{"text":"2222"},"line_number":5,"absolute_offset":8,"submatches":[]}}
+	// {"type":"end","data":{"path":{"text":"1"},"binary":false,"stats":{"matched_lines":1,"matches":1}}}
+
+	// При выводе в терминал (или с флагом --color=always) подсвечиваются только совпадающие части строк.
+	// Цвета задаются переменной окружения GREP_COLORS, например GREP_COLORS='ms=01;32:fn=34'.
+
+	// 2) Поиск совпадений в нескольких файлах
+	// Структура директории:
+	// a/x.txt      (hello, world)
+	// a/skip/y.txt (hello go)
+	// b/z.log      (nothing)
+	// b/bin.dat    (бинарный файл, содержащий hello)
+
+	// Вывод программы с флагами -r hello:
+	// a/skip/y.txt: hello go
+	// a/x.txt: hello
+	// Binary file b/bin.dat matches
+
+	// Вывод программы с флагами -r --include=*.txt --exclude-dir=skip -n hello:
+	// a/x.txt: 1: hello
+
+	// Вывод программы с флагами -r -L hello:
+	// b/z.log
+
+	// Вывод программы с флагами -c hello a/x.txt b/z.log:
+	// a/x.txt: 1
+	// b/z.log: 0
+
+	// Вывод программы с флагами -q hello a/x.txt nofile (код завершения 0):
+
+	// Вывод программы с флагами hello a/x.txt nofile (код завершения 2):
+	// a/x.txt: hello
+	// go-grep: stat nofile: no such file or directory
+
+	// Вывод программы с флагами -s hello nofile (код завершения 2):
+
+	// Поиск в сжатых файлах и tar-архивах. Формат сжатия определяется по первым байтам файла.
+	// Вывод программы с флагами -z -n error app.log.gz app.log.zst logs.tar.gz:
+	// app.log.gz: 2: error one
+	// app.log.zst: 2: error one
+	// logs.tar.gz:other.txt: 1: error two
+	// logs.tar.gz:logs/app.log: 2: error one
+
+	// Поиск в файлах не в UTF-8. Кодировка UTF-16 определяется по метке BOM, остальные кодировки указываются явно.
+	// Смещения (флаг -b) считаются в байтах строк, перекодированных в UTF-8.
+	// Вывод программы с флагами -n -i ошибка utf16.txt:
+	// 2: Ошибка доступа
+
+	// Вывод программы с флагами --encoding=windows-1251 -b пока cp1251.txt:
+	// 41: пока
+
+	// Слежение за растущим файлом. Строки выводятся по мере их дописывания в app.log,
+	// поиск продолжается после ротации и усечения файла (номера строк при этом отсчитываются заново)
+	// и завершается по Ctrl+C.
+	// Вывод программы с флагами --follow -n error app.log (после echo "error three" >> app.log):
+	// 2: error one
+	// 4: error three
+	// ^C
+
+	// 3) Поиск совпадений при вводе данных в stdin
+	// Данные из stdin обрабатываются так же, как и данные из файла: строки выводятся сразу после ввода,
+	// окончания строк \n и \r\n поддерживаются одинаково, ввод завершается по EOF (^D).
+	// Длина строки не ограничена. С флагом --null-data строки разделяются нулевым байтом как при вводе,
+	// так и при выводе, например, при поиске в выводе find -print0.
 
 	// Вывод программы с флагами -A 2 s:
 	// 2
@@ -595,6 +831,7 @@ func main() {
 	// 4
 	// 5
 	// 1
+	// --
 	// 5: 5
 	// 6: 1
 	// 6
@@ -606,12 +843,9 @@ func main() {
 	// 2
 	// 3
 	// 1
-	// 3: 1
 	// 45
 	// 12
-	// 5: 12
 	// 61
-	// 6: 61
 	// 2
 	// ^D
 	// 3
@@ -638,10 +872,11 @@ func main() {
 	// 6: 2
 	// ^D
 
-	// Вывод программы с флагами -F -n 12:
+	// Вывод программы с флагами -F -n 1.:
 	// 12
-	// 1: 12
-	// 123
-	// 3124
+	// 1.5
+	// 2: 1.5
+	// 31.
+	// 3: 31.
 	// ^D
 }
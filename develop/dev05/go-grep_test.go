@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Переходит в директорию dir на время теста, чтобы имена файлов в выводе совпадали с примерами из main.
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+}
+
+// Тестирование всех примеров работы программы, приведенных в комментариях к функции main.
+func TestGrep_Search(t *testing.T) {
+	testData := []struct {
+		// Директория, в которой запускается программа.
+		dir string
+		// Аргументы командной строки.
+		args []string
+		// Данные, вводимые в stdin.
+		stdin string
+		// Ожидаемый вывод в stdout.
+		expected string
+		// Подстрока, которая должна содержаться в выводе в stderr. Если пустая, то stderr должен быть пустым.
+		expectedErr string
+		// Ожидаемый код завершения.
+		expectedCode int
+	}{
+		// 1) Поиск совпадений в файле
+		{
+			dir:      "test_files/file",
+			args:     []string{"-A", "3", "2", "1"},
+			expected: "2\n2\n3\n2222\nabcd\n12a\n324\nабвгд\n\n34\n2\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-B", "2", "3", "1"},
+			expected: "2\n2\n3\n--\nabcd\n12a\n324\nабвгд\n\n34\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-C", "1", "3", "1"},
+			expected: "2\n3\n2222\n--\n12a\n324\nабвгд\n\n34\n2\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-c", "3", "1"},
+			expected: "3\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-C", "1", "-i", "Ab", "1"},
+			expected: "2222\nabcd\n12a\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-v", "-A", "1", "-n", "2", "1"},
+			expected: "1: 1\n2: 2\n--\n4: 3\n5: 2222\n6: abcd\n7: 12a\n--\n9: абвгд\n10: \n11: 34\n12: 2\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-v", "2", "1"},
+			expected: "1\n3\nabcd\nабвгд\n\n34\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-F", "2", "1"},
+			expected: "2\n2\n2222\n12a\n324\n2\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-C", "1", "-E", "-n", "^(1|3)$", "1"},
+			expected: "1: 1\n2: 2\n3: 2\n4: 3\n5: 2222\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-i", "-P", "-n", `^A\w+`, "1"},
+			expected: "6: abcd\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{`2\{4\}`, "1"},
+			expected: "2222\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-E", "-o", "-b", "2+", "1"},
+			expected: "2: 2\n4: 2\n8: 2222\n19: 2\n23: 2\n41: 2\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-w", "-n", "2", "1"},
+			expected: "2: 2\n3: 2\n12: 2\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-x", "2", "1"},
+			expected: "2\n2\n2\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-e", "34", "-e", "abcd", "-n", "1"},
+			expected: "6: abcd\n11: 34\n",
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-c", "-f", "patterns.txt", "1"},
+			expected: "7\n",
+		},
+		{
+			dir:  "test_files/file",
+			args: []string{"--json", "-A", "1", "-w", "3", "1"},
+			expected: `{"type":"begin","data":{"path":{"text":"1"}}}
+{"type":"match","data":{"path":{"text":"1"},"line":{"text":"3"},"line_number":4,"absolute_offset":6,"submatches":[{"match":{"text":"3"},"start":0,"end":1}]}}
+{"type":"context","data":{"path":{"text":"1"},"line":{"text":"2222"},"line_number":5,"absolute_offset":8,"submatches":[]}}
+{"type":"end","data":{"path":{"text":"1"},"binary":false,"stats":{"matched_lines":1,"matches":1}}}
+`,
+		},
+		{
+			dir:          "test_files/file",
+			args:         []string{"zzz", "1"},
+			expectedCode: exitNoMatch,
+		},
+		{
+			dir:      "test_files/file",
+			args:     []string{"-m", "1", "-A", "2", "-n", "2", "1"},
+			expected: "2: 2\n3: 2\n4: 3\n",
+		},
+		{
+			dir:          "test_files/file",
+			args:         []string{"-m", "0", "-c", "2", "1"},
+			expected:     "0\n",
+			expectedCode: exitNoMatch,
+		},
+
+		// 2) Поиск совпадений в нескольких файлах
+		{
+			dir:      "test_files/tree",
+			args:     []string{"-r", "hello"},
+			expected: "a/skip/y.txt: hello go\na/x.txt: hello\nBinary file b/bin.dat matches\n",
+		},
+		{
+			dir:      "test_files/tree",
+			args:     []string{"-r", "-j", "4", "hello"},
+			expected: "a/skip/y.txt: hello go\na/x.txt: hello\nBinary file b/bin.dat matches\n",
+		},
+		{
+			dir:      "test_files/tree",
+			args:     []string{"-r", "--include=*.txt", "--exclude-dir=skip", "-n", "hello"},
+			expected: "a/x.txt: 1: hello\n",
+		},
+		{
+			dir:      "test_files/tree",
+			args:     []string{"-r", "-L", "hello"},
+			expected: "b/z.log\n",
+		},
+		{
+			dir:      "test_files/tree",
+			args:     []string{"-c", "hello", "a/x.txt", "b/z.log"},
+			expected: "a/x.txt: 1\nb/z.log: 0\n",
+		},
+		{
+			dir:          "test_files/tree",
+			args:         []string{"-q", "hello", "a/x.txt", "nofile"},
+			expectedCode: exitMatch,
+		},
+		{
+			dir:          "test_files/tree",
+			args:         []string{"hello", "a/x.txt", "nofile"},
+			expected:     "a/x.txt: hello\n",
+			expectedErr:  "go-grep: stat nofile: no such file or directory",
+			expectedCode: exitError,
+		},
+		{
+			dir:          "test_files/tree",
+			args:         []string{"-s", "hello", "nofile"},
+			expectedCode: exitError,
+		},
+
+		// Поиск в сжатых файлах и tar-архивах
+		{
+			dir:      "test_files/archive",
+			args:     []string{"-z", "-n", "error", "app.log.gz", "app.log.bz2", "app.log.zst", "logs.tar.gz"},
+			expected: "app.log.gz: 2: error one\napp.log.bz2: 2: error one\napp.log.zst: 2: error one\nlogs.tar.gz:other.txt: 1: error two\nlogs.tar.gz:logs/app.log: 2: error one\n",
+		},
+		{
+			dir:      "test_files/archive",
+			args:     []string{"-z", "-c", "error", "logs.tar.gz"},
+			expected: "logs.tar.gz:other.txt: 1\nlogs.tar.gz:logs/app.log: 1\n",
+		},
+		{
+			dir:          "test_files/archive",
+			args:         []string{"error", "app.log.gz"},
+			expectedCode: exitNoMatch,
+		},
+
+		// Поиск в файлах в кодировках UTF-16, Windows-1251 и KOI8-R
+		{
+			dir:      "test_files/encoding",
+			args:     []string{"-n", "-i", "ошибка", "utf16.txt"},
+			expected: "2: Ошибка доступа\n",
+		},
+		{
+			dir:      "test_files/encoding",
+			args:     []string{"--encoding=windows-1251", "-b", "пока", "cp1251.txt"},
+			expected: "41: пока\n",
+		},
+		{
+			dir:      "test_files/encoding",
+			args:     []string{"--encoding=koi8-r", "-c", "-w", "доступа", "koi8r.txt"},
+			expected: "1\n",
+		},
+
+		// 3) Поиск совпадений при вводе данных в stdin
+		{
+			args:     []string{"-A", "2", "s"},
+			stdin:    "2\n3\nsss\n2\n90\n1\n",
+			expected: "sss\n2\n90\n",
+		},
+		{
+			args:     []string{"-B", "2", "-n", "1"},
+			stdin:    "1\n23\n4\n1\n2\n1\n",
+			expected: "1: 1\n2: 23\n3: 4\n4: 1\n5: 2\n6: 1\n",
+		},
+		{
+			args:     []string{"-C", "1", "-n", "1"},
+			stdin:    "1\n2\n3\n4\n5\n1\n6\n8\n",
+			expected: "1: 1\n2: 2\n--\n5: 5\n6: 1\n7: 6\n",
+		},
+		{
+			args:     []string{"-c", "-n", "1"},
+			stdin:    "2\n3\n1\n45\n12\n61\n2\n",
+			expected: "3\n",
+		},
+		{
+			args:     []string{"-i", "-n", "AAb"},
+			stdin:    "a\naab\nAAB\nf\n",
+			expected: "2: aab\n3: AAB\n",
+		},
+		{
+			args:     []string{"-v", "-n", "1"},
+			stdin:    "1\na\nb\nc\n123\n2\n",
+			expected: "2: a\n3: b\n4: c\n6: 2\n",
+		},
+		{
+			args:     []string{"-F", "-n", "1."},
+			stdin:    "12\r\n1.5\r\n31.\r\n",
+			expected: "2: 1.5\n3: 31.\n",
+		},
+		{
+			args:     []string{"--null-data", "-n", "a.b"},
+			stdin:    "a\nb\x00ab\x00a b",
+			expected: "1: a\nb\x003: a b\x00",
+		},
+	}
+
+	for _, data := range testData {
+		t.Run(strings.Join(data.args, " "), func(t *testing.T) {
+			if data.dir != "" {
+				chdir(t, data.dir)
+			}
+
+			var stdout, stderr bytes.Buffer
+			g, err := NewGrep(data.args, strings.NewReader(data.stdin), &stdout, &stderr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			code := g.Search(context.Background())
+			assert.Equal(t, data.expectedCode, code)
+			assert.Equal(t, data.expected, stdout.String())
+			if data.expectedErr == "" {
+				assert.Empty(t, stderr.String())
+			} else {
+				assert.Contains(t, stderr.String(), data.expectedErr)
+			}
+		})
+	}
+}
+
+// Тестирование ошибок в аргументах командной строки.
+func TestNewGrep(t *testing.T) {
+	invalidTestData := [][]string{
+		{},
+		{"-E", "-F", "a"},
+		{"-E", "(", "1"},
+		{"-j", "-1", "a"},
+		{"--color=sometimes", "a"},
+		{"-unknown", "a"},
+		{"--json", "-c", "a"},
+		{"--encoding=latin1", "a"},
+		{"--follow", "-r", "a"},
+	}
+
+	for _, args := range invalidTestData {
+		var stdout, stderr bytes.Buffer
+		_, err := NewGrep(args, strings.NewReader(""), &stdout, &stderr)
+		assert.Error(t, err, args)
+	}
+}
@@ -1,3 +1,15 @@
 module github.com/hurstcain/tasks_l2/develop/dev05
 
 go 1.17
+
+require (
+	github.com/klauspost/compress v1.15.15
+	github.com/stretchr/testify v1.7.0
+	golang.org/x/text v0.13.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
@@ -0,0 +1,263 @@
+package grep
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Вершина бора автомата Ахо-Корасик.
+type acNode struct {
+	// Переходы по символам.
+	next map[rune]int32
+	// Суффиксная ссылка - вершина, соответствующая самому длинному собственному суффиксу строки вершины.
+	fail int32
+	// Ближайшая по суффиксным ссылкам вершина, в которой заканчивается паттерн, или -1.
+	dict int32
+	// Длина строки вершины в символах.
+	depth int32
+	// Заканчивается ли в вершине какой-либо паттерн.
+	terminal bool
+}
+
+// acMatcher - поиск любой из множества фиксированных строк с помощью алгоритма Ахо-Корасик.
+// Время поиска не зависит от количества паттернов, поэтому используется при флаге -F,
+// если паттернов больше одного или указан один из флагов -i, -w, -x.
+type acMatcher struct {
+	nodes []acNode
+	// Есть ли среди паттернов пустая строка.
+	hasEmpty   bool
+	ignoreCase bool
+	// Совпадение должно быть целым словом (флаг -w).
+	wordRegexp bool
+	// Совпадение должно быть целой строкой (флаг -x).
+	lineRegexp bool
+}
+
+// Создает автомат Ахо-Корасик для паттернов patterns.
+func newACMatcher(patterns []string, ignoreCase, wordRegexp, lineRegexp bool) *acMatcher {
+	m := &acMatcher{
+		nodes:      []acNode{{next: make(map[rune]int32), dict: -1}},
+		ignoreCase: ignoreCase,
+		wordRegexp: wordRegexp && !lineRegexp,
+		lineRegexp: lineRegexp,
+	}
+
+	// Построение бора.
+	for _, pattern := range patterns {
+		if pattern == "" {
+			m.hasEmpty = true
+			continue
+		}
+
+		var cur int32
+		for _, r := range pattern {
+			r = m.fold(r)
+			next, ok := m.nodes[cur].next[r]
+			if !ok {
+				next = int32(len(m.nodes))
+				m.nodes = append(m.nodes, acNode{
+					next:  make(map[rune]int32),
+					dict:  -1,
+					depth: m.nodes[cur].depth + 1,
+				})
+				m.nodes[cur].next[r] = next
+			}
+			cur = next
+		}
+		m.nodes[cur].terminal = true
+	}
+
+	// Построение суффиксных ссылок обходом бора в ширину.
+	queue := make([]int32, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for r, child := range m.nodes[cur].next {
+			fail := m.nodes[cur].fail
+			for {
+				if next, ok := m.nodes[fail].next[r]; ok {
+					fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+
+			m.nodes[child].fail = fail
+			if m.nodes[fail].terminal {
+				m.nodes[child].dict = fail
+			} else {
+				m.nodes[child].dict = m.nodes[fail].dict
+			}
+			queue = append(queue, child)
+		}
+	}
+
+	return m
+}
+
+// Приводит символ к единому регистру, если регистр игнорируется.
+// Символы, отличающиеся только регистром, приводятся к наименьшему символу из их класса эквивалентности.
+func (m *acMatcher) fold(r rune) rune {
+	if !m.ignoreCase {
+		return r
+	}
+	if r < utf8.RuneSelf {
+		if 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return r
+	}
+
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < folded {
+			folded = f
+		}
+	}
+
+	return folded
+}
+
+// Переход автомата из вершины cur по символу r.
+func (m *acMatcher) step(cur int32, r rune) int32 {
+	for {
+		if next, ok := m.nodes[cur].next[r]; ok {
+			return next
+		}
+		if cur == 0 {
+			return 0
+		}
+		cur = m.nodes[cur].fail
+	}
+}
+
+// Вызывает found для каждого вхождения паттерна в строку s, включая пересекающиеся,
+// в порядке возрастания позиции конца вхождения. Пустые вхождения не передаются.
+// Если found возвращает false, поиск прекращается.
+func (m *acMatcher) occurrences(s string, found func(start, end int) bool) {
+	var cur int32
+
+	for end := 0; end < len(s); {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		end += size
+
+		cur = m.step(cur, m.fold(r))
+		for node := cur; node > 0; node = m.nodes[node].dict {
+			if !m.nodes[node].terminal {
+				continue
+			}
+			// Начало вхождения находится на depth символов левее его конца.
+			start := end
+			for i := int32(0); i < m.nodes[node].depth; i++ {
+				_, size := utf8.DecodeLastRuneInString(s[:start])
+				start -= size
+			}
+			if !found(start, end) {
+				return
+			}
+		}
+	}
+}
+
+// Проверяет, удовлетворяет ли вхождение [start, end) флагам -w и -x.
+func (m *acMatcher) accept(s string, start, end int) bool {
+	switch {
+	case m.lineRegexp:
+		return start == 0 && end == len(s)
+	case m.wordRegexp:
+		return isWordBoundary(s, start, end)
+	default:
+		return true
+	}
+}
+
+func (m *acMatcher) match(s string) bool {
+	if m.hasEmpty {
+		switch {
+		case m.lineRegexp:
+			if s == "" {
+				return true
+			}
+		case m.wordRegexp:
+			for i := range s {
+				if isWordBoundary(s, i, i) {
+					return true
+				}
+			}
+			if isWordBoundary(s, len(s), len(s)) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+
+	matched := false
+	m.occurrences(s, func(start, end int) bool {
+		matched = m.accept(s, start, end)
+		return !matched
+	})
+
+	return matched
+}
+
+func (m *acMatcher) findAll(s string) [][]int {
+	all := make([][]int, 0)
+	m.occurrences(s, func(start, end int) bool {
+		if m.accept(s, start, end) {
+			all = append(all, []int{start, end})
+		}
+		return true
+	})
+
+	// Из всех вхождений выбираются самые левые и самые длинные непересекающиеся.
+	sort.Slice(all, func(i, j int) bool {
+		if all[i][0] != all[j][0] {
+			return all[i][0] < all[j][0]
+		}
+		return all[i][1] > all[j][1]
+	})
+
+	spans := make([][]int, 0)
+	last := 0
+	for _, span := range all {
+		if span[0] >= last {
+			spans = append(spans, span)
+			last = span[1]
+		}
+	}
+
+	return spans
+}
+
+// Проверяет, является ли подстрока s[start:end] отдельным словом,
+// то есть перед ней и после нее нет символов, из которых состоят слова.
+func isWordBoundary(s string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(s[:start])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(s) {
+		r, _ := utf8.DecodeRuneInString(s[end:])
+		if isWordRune(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Символы, из которых состоят слова: буквы, цифры и знак подчеркивания.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
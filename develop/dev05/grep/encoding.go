@@ -0,0 +1,98 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Метки порядка байтов (BOM), по которым определяется кодировка при Options.Encoding = "".
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// Поддерживаемые кодировки входных данных. Ключ - название кодировки в нижнем регистре.
+// Для кодировок UTF-16 метка BOM в начале данных важнее, чем указанный порядок байтов.
+var encodings = map[string]encoding.Encoding{
+	"utf-8":        unicode.UTF8BOM,
+	"utf8":         unicode.UTF8BOM,
+	"utf-16":       unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16le":     unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16be":     unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"windows-1251": charmap.Windows1251,
+	"cp1251":       charmap.Windows1251,
+	"koi8-r":       charmap.KOI8R,
+}
+
+// Возвращает кодировку с названием name. Пустое название означает автоматическое определение
+// кодировки по метке BOM, при этом возвращается nil.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	enc, ok := encodings[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная кодировка %s", name)
+	}
+
+	return enc, nil
+}
+
+// Возвращает reader, из которого читаются данные reader, перекодированные из кодировки enc в UTF-8.
+// Если enc равна nil, то кодировка определяется по метке BOM в начале данных: UTF-16 перекодируется,
+// у UTF-8 метка отбрасывается, а данные без метки считаются данными в UTF-8 и возвращаются без изменений.
+func decode(reader *bufio.Reader, enc encoding.Encoding) io.Reader {
+	if enc == nil {
+		switch header := bomHeader(reader); {
+		case bytes.HasPrefix(header, utf8BOM):
+			reader.Discard(len(utf8BOM))
+			return reader
+		case bytes.HasPrefix(header, utf16LEBOM), bytes.HasPrefix(header, utf16BEBOM):
+			enc = encodings["utf-16"]
+		default:
+			return reader
+		}
+	}
+
+	return transform.NewReader(reader, enc.NewDecoder())
+}
+
+// Возвращает начало данных для определения метки BOM.
+// Как и в isBinary, данные читаются одним чтением, чтобы не ждать следующих строк интерактивного ввода
+// или растущего файла: короткая первая строка сразу доступна для поиска. Следующие байты ожидаются,
+// только если прочитанные байты являются началом метки BOM.
+func bomHeader(reader *bufio.Reader) []byte {
+	reader.Peek(1)
+	header, _ := reader.Peek(reader.Buffered())
+	for isBOMPrefix(header) {
+		var err error
+		if header, err = reader.Peek(len(header) + 1); err != nil {
+			break
+		}
+	}
+
+	return header
+}
+
+// Проверяет, являются ли байты data началом, но не целой меткой BOM.
+func isBOMPrefix(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	for _, bom := range [][]byte{utf8BOM, utf16LEBOM, utf16BEBOM} {
+		if len(data) < len(bom) && bytes.HasPrefix(bom, data) {
+			return true
+		}
+	}
+	return false
+}
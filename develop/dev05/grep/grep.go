@@ -0,0 +1,135 @@
+// Package grep реализует построчный поиск совпадений с паттернами в потоке данных так же,
+// как это делает утилита grep: режимы интерпретации паттернов, игнорирование регистра,
+// поиск целых слов и строк, исключение совпадений, строки до и после совпадения
+// и ограничение количества совпадений. Поддерживаются данные в кодировках UTF-8, UTF-16,
+// Windows-1251 и KOI8-R, записи, разделенные нулевым байтом, и строки любой длины.
+//
+// Пример использования:
+//
+//	matches, err := grep.Search(ctx, os.Stdin, grep.Options{Patterns: []string{"ERROR"}, After: 2})
+//	if err != nil {
+//		return err
+//	}
+//	for matches.Next() {
+//		m := matches.Match()
+//		fmt.Println(m.LineNum, m.Line)
+//	}
+//	if err := matches.Err(); err != nil {
+//		return err
+//	}
+package grep
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"golang.org/x/text/encoding"
+)
+
+// Mode - режим интерпретации паттернов.
+type Mode int
+
+// Режимы интерпретации паттернов.
+const (
+	// BasicRegexp - базовое регулярное выражение POSIX (флаг -G, режим по умолчанию).
+	BasicRegexp Mode = iota
+	// ExtendedRegexp - расширенное регулярное выражение POSIX (флаг -E).
+	ExtendedRegexp
+	// PerlRegexp - регулярное выражение в синтаксисе Go RE2 (флаг -P).
+	PerlRegexp
+	// FixedString - фиксированная строка, поиск подстроки (флаг -F).
+	FixedString
+)
+
+// Options - параметры поиска. Нулевое значение полей соответствует поведению утилиты grep без флагов.
+type Options struct {
+	// Паттерны для поиска совпадений. Строка совпадает, если она соответствует хотя бы одному из них.
+	// Если паттернов нет, то ни одна строка не совпадает.
+	Patterns []string
+	// Режим интерпретации паттернов (флаги -G, -E, -P, -F).
+	Mode Mode
+	// Игнорировать регистр (флаг -i).
+	IgnoreCase bool
+	// Совпадение должно быть целым словом (флаг -w).
+	WordRegexp bool
+	// Совпадение должно быть целой строкой (флаг -x). Важнее, чем WordRegexp.
+	LineRegexp bool
+	// Выбирать строки, которые не соответствуют паттернам (флаг -v).
+	Invert bool
+	// Количество строк до выбранной строки (флаг -B).
+	Before int
+	// Количество строк после выбранной строки (флаг -A).
+	After int
+	// Максимальное количество выбранных строк (флаг -m). Строки после последней выбранной
+	// строки при этом все равно возвращаются. Если значение не положительное, то количество не ограничено.
+	MaxCount int
+	// Кодировка данных (флаг --encoding): utf-8, utf-16, utf-16le, utf-16be, windows-1251 (cp1251) или koi8-r.
+	// Данные перекодируются в UTF-8 перед поиском. Если значение пустое, то кодировка UTF-16
+	// определяется по метке BOM в начале данных, а остальные данные считаются данными в UTF-8.
+	Encoding string
+	// Записи разделяются нулевым байтом, а не символом конца строки (флаг --null-data).
+	// Символы \n при этом являются обычными символами записи, которым в регулярных выражениях
+	// соответствуют . и [^...], а данные не считаются бинарными.
+	NullData bool
+}
+
+// Match - строка, найденная при поиске.
+type Match struct {
+	// Номер строки, начиная с 1.
+	LineNum int
+	// Смещение начала строки в байтах от начала данных. Для данных не в UTF-8
+	// смещение считается в байтах данных, перекодированных в UTF-8.
+	Offset int64
+	// Строка без символов конца строки \n и \r\n (или без нулевого байта при Options.NullData).
+	Line string
+	// Является ли строка строкой до или после выбранной строки, а не выбранной строкой.
+	Context bool
+	// Позиции [начало, конец) непересекающихся непустых совпадений с паттернами в строке.
+	// Пустой, если строка не соответствует паттернам, например, выбранная строка при Options.Invert.
+	Spans [][]int
+}
+
+// Searcher - паттерны, подготовленные для поиска.
+// Может использоваться из нескольких горутин одновременно.
+type Searcher struct {
+	matcher  matcher
+	encoding encoding.Encoding
+	opts     Options
+}
+
+// New - конструктор структуры Searcher. Возвращает ошибку, если какой-либо из паттернов некорректен
+// или кодировка Options.Encoding не поддерживается.
+func New(opts Options) (*Searcher, error) {
+	enc, err := lookupEncoding(opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMatcher(opts.Patterns, opts.Mode, opts.IgnoreCase, opts.WordRegexp, opts.LineRegexp, opts.NullData)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный паттерн: %w", err)
+	}
+
+	return &Searcher{
+		matcher:  m,
+		encoding: enc,
+		opts:     opts,
+	}, nil
+}
+
+// Search - начинает поиск совпадений в данных из r. Данные читаются по мере вызова Matches.Next.
+func (s *Searcher) Search(ctx context.Context, r io.Reader) *Matches {
+	return newMatches(ctx, s, r)
+}
+
+// Search - начинает поиск совпадений в данных из r с параметрами opts.
+// Для поиска в нескольких источниках с одними параметрами лучше один раз создать Searcher.
+func Search(ctx context.Context, r io.Reader, opts Options) (*Matches, error) {
+	s, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Search(ctx, r), nil
+}
@@ -0,0 +1,290 @@
+package grep
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Читает все строки из потока matches.
+func collect(t *testing.T, matches *Matches) []Match {
+	result := make([]Match, 0)
+	for matches.Next() {
+		result = append(result, matches.Match())
+	}
+	assert.NoError(t, matches.Err())
+
+	return result
+}
+
+func TestSearch(t *testing.T) {
+	const input = "1\n2\n2\n3\n2222\nabcd\n12a\n324\nабвгд\n\n34\n2\n"
+
+	testData := []struct {
+		name     string
+		opts     Options
+		expected []Match
+	}{
+		{
+			name: "fixed string",
+			opts: Options{Patterns: []string{"2"}, Mode: FixedString},
+			expected: []Match{
+				{LineNum: 2, Offset: 2, Line: "2", Spans: [][]int{{0, 1}}},
+				{LineNum: 3, Offset: 4, Line: "2", Spans: [][]int{{0, 1}}},
+				{LineNum: 5, Offset: 8, Line: "2222", Spans: [][]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}}},
+				{LineNum: 7, Offset: 18, Line: "12a", Spans: [][]int{{1, 2}}},
+				{LineNum: 8, Offset: 22, Line: "324", Spans: [][]int{{1, 2}}},
+				{LineNum: 12, Offset: 41, Line: "2", Spans: [][]int{{0, 1}}},
+			},
+		},
+		{
+			name: "extended regexp is leftmost-longest",
+			opts: Options{Patterns: []string{"2|22+"}, Mode: ExtendedRegexp},
+			expected: []Match{
+				{LineNum: 2, Offset: 2, Line: "2", Spans: [][]int{{0, 1}}},
+				{LineNum: 3, Offset: 4, Line: "2", Spans: [][]int{{0, 1}}},
+				{LineNum: 5, Offset: 8, Line: "2222", Spans: [][]int{{0, 4}}},
+				{LineNum: 7, Offset: 18, Line: "12a", Spans: [][]int{{1, 2}}},
+				{LineNum: 8, Offset: 22, Line: "324", Spans: [][]int{{1, 2}}},
+				{LineNum: 12, Offset: 41, Line: "2", Spans: [][]int{{0, 1}}},
+			},
+		},
+		{
+			name: "basic regexp with context",
+			opts: Options{Patterns: []string{`^3\{1\}`}, Before: 1, After: 1},
+			expected: []Match{
+				{LineNum: 3, Offset: 4, Line: "2", Context: true},
+				{LineNum: 4, Offset: 6, Line: "3", Spans: [][]int{{0, 1}}},
+				{LineNum: 5, Offset: 8, Line: "2222", Context: true},
+				{LineNum: 7, Offset: 18, Line: "12a", Context: true},
+				{LineNum: 8, Offset: 22, Line: "324", Spans: [][]int{{0, 1}}},
+				{LineNum: 9, Offset: 26, Line: "абвгд", Context: true},
+				{LineNum: 10, Offset: 37, Line: "", Context: true},
+				{LineNum: 11, Offset: 38, Line: "34", Spans: [][]int{{0, 1}}},
+				{LineNum: 12, Offset: 41, Line: "2", Context: true},
+			},
+		},
+		{
+			name: "invert with context",
+			opts: Options{Patterns: []string{"[0-9]"}, Invert: true, After: 1},
+			expected: []Match{
+				{LineNum: 6, Offset: 13, Line: "abcd"},
+				{LineNum: 7, Offset: 18, Line: "12a", Context: true, Spans: [][]int{{0, 1}, {1, 2}}},
+				{LineNum: 9, Offset: 26, Line: "абвгд"},
+				{LineNum: 10, Offset: 37, Line: ""},
+				{LineNum: 11, Offset: 38, Line: "34", Context: true, Spans: [][]int{{0, 1}, {1, 2}}},
+			},
+		},
+		{
+			name: "ignore case, word and line",
+			opts: Options{Patterns: []string{"ABCD", "2"}, IgnoreCase: true, WordRegexp: true},
+			expected: []Match{
+				{LineNum: 2, Offset: 2, Line: "2", Spans: [][]int{{0, 1}}},
+				{LineNum: 3, Offset: 4, Line: "2", Spans: [][]int{{0, 1}}},
+				{LineNum: 6, Offset: 13, Line: "abcd", Spans: [][]int{{0, 4}}},
+				{LineNum: 12, Offset: 41, Line: "2", Spans: [][]int{{0, 1}}},
+			},
+		},
+		{
+			name: "max count with trailing context",
+			opts: Options{Patterns: []string{"2"}, MaxCount: 2, After: 2},
+			expected: []Match{
+				{LineNum: 2, Offset: 2, Line: "2", Spans: [][]int{{0, 1}}},
+				{LineNum: 3, Offset: 4, Line: "2", Spans: [][]int{{0, 1}}},
+				{LineNum: 4, Offset: 6, Line: "3", Context: true},
+				{LineNum: 5, Offset: 8, Line: "2222", Context: true, Spans: [][]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}}},
+			},
+		},
+		{
+			name:     "no patterns",
+			opts:     Options{},
+			expected: []Match{},
+		},
+	}
+
+	for _, data := range testData {
+		t.Run(data.name, func(t *testing.T) {
+			matches, err := Search(context.Background(), strings.NewReader(input), data.opts)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.False(t, matches.Binary())
+			assert.Equal(t, data.expected, collect(t, matches))
+		})
+	}
+}
+
+func TestSearch_binary(t *testing.T) {
+	matches, err := Search(context.Background(), strings.NewReader("hel\x00lo\nhello\n"), Options{Patterns: []string{"hello"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, matches.Binary())
+	assert.Equal(t, []Match{{LineNum: 2, Offset: 7, Line: "hello", Spans: [][]int{{0, 5}}}}, collect(t, matches))
+}
+
+func TestSearch_encoding(t *testing.T) {
+	testData := []struct {
+		name     string
+		encoding string
+		input    string
+		expected []Match
+	}{
+		{
+			name:     "windows-1251",
+			encoding: "windows-1251",
+			input:    "\xef\xf0\xe8\xe2\xe5\xf2\n\xef\xee\xea\xe0\n",
+			expected: []Match{{LineNum: 2, Offset: 13, Line: "пока", Spans: [][]int{{0, 8}}}},
+		},
+		{
+			name:     "koi8-r",
+			encoding: "KOI8-R",
+			input:    "\xd0\xd2\xc9\xd7\xc5\xd4\n\xd0\xcf\xcb\xc1\n",
+			expected: []Match{{LineNum: 2, Offset: 13, Line: "пока", Spans: [][]int{{0, 8}}}},
+		},
+		{
+			name:     "utf-16le with BOM",
+			input:    "\xff\xfe\x1f\x04\x40\x04\n\x00\x3f\x04\x3e\x04\x3a\x04\x30\x04\n\x00",
+			expected: []Match{{LineNum: 2, Offset: 5, Line: "пока", Spans: [][]int{{0, 8}}}},
+		},
+		{
+			name:     "utf-16be",
+			encoding: "utf-16be",
+			input:    "\x04\x3f\x04\x3e\x04\x3a\x04\x30",
+			expected: []Match{{LineNum: 1, Offset: 0, Line: "пока", Spans: [][]int{{0, 8}}}},
+		},
+		{
+			name:     "utf-8 with BOM",
+			input:    "\xef\xbb\xbfпока\n",
+			expected: []Match{{LineNum: 1, Offset: 0, Line: "пока", Spans: [][]int{{0, 8}}}},
+		},
+	}
+
+	for _, data := range testData {
+		t.Run(data.name, func(t *testing.T) {
+			matches, err := Search(context.Background(), strings.NewReader(data.input), Options{Patterns: []string{"пока"}, Encoding: data.encoding})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.False(t, matches.Binary())
+			assert.Equal(t, data.expected, collect(t, matches))
+		})
+	}
+}
+
+func TestSearch_hugeBefore(t *testing.T) {
+	// Память под строки до выбранной строки выделяется по мере чтения, а не по значению Options.Before.
+	matches, err := Search(context.Background(), strings.NewReader("a\nb\nc\n"), Options{Patterns: []string{"c"}, Before: 100000000000})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []Match{
+		{LineNum: 1, Offset: 0, Line: "a", Context: true},
+		{LineNum: 2, Offset: 2, Line: "b", Context: true},
+		{LineNum: 3, Offset: 4, Line: "c", Spans: [][]int{{0, 1}}},
+	}, collect(t, matches))
+}
+
+func TestBeforeDataRing(t *testing.T) {
+	r := newBeforeDataRing(3)
+	lines := func() []string {
+		result := make([]string, 0)
+		r.Flush(func(data beforeData) { result = append(result, data.match.Line) })
+		return result
+	}
+
+	for _, line := range []string{"1", "2"} {
+		r.Write(beforeData{match: Match{Line: line}})
+	}
+	assert.Equal(t, []string{"1", "2"}, lines())
+
+	for _, line := range []string{"1", "2", "3", "4", "5"} {
+		r.Write(beforeData{match: Match{Line: line}})
+	}
+	assert.Equal(t, []string{"3", "4", "5"}, lines())
+	assert.Len(t, r.data, 3)
+
+	r.Write(beforeData{match: Match{Line: "6"}})
+	assert.Equal(t, []string{"6"}, lines())
+}
+
+func TestSearch_slowReader(t *testing.T) {
+	// Первая строка должна находиться сразу, не дожидаясь следующих данных, как при чтении
+	// интерактивного ввода или растущего файла.
+	r, w := io.Pipe()
+	defer w.Close()
+	go w.Write([]byte("1\n"))
+
+	found := make(chan Match, 1)
+	go func() {
+		matches, err := Search(context.Background(), r, Options{Patterns: []string{"1"}})
+		if err == nil && matches.Next() {
+			found <- matches.Match()
+		}
+	}()
+
+	select {
+	case match := <-found:
+		assert.Equal(t, Match{LineNum: 1, Offset: 0, Line: "1", Spans: [][]int{{0, 1}}}, match)
+	case <-time.After(time.Second):
+		t.Fatal("строка не найдена до получения следующих данных")
+	}
+}
+
+func TestSearch_nullData(t *testing.T) {
+	matches, err := Search(context.Background(), strings.NewReader("a\nb\x00c\x00ab\r"), Options{Patterns: []string{"a.b"}, NullData: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.False(t, matches.Binary())
+	assert.Equal(t, []Match{{LineNum: 1, Offset: 0, Line: "a\nb", Spans: [][]int{{0, 3}}}}, collect(t, matches))
+}
+
+func TestSearch_longLine(t *testing.T) {
+	line := strings.Repeat("a", 1<<20) + "b"
+	matches, err := Search(context.Background(), strings.NewReader("x\n"+line+"\ny\n"), Options{Patterns: []string{"ab"}, Mode: FixedString})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []Match{{LineNum: 2, Offset: 2, Line: line, Spans: [][]int{{1<<20 - 1, 1<<20 + 1}}}}, collect(t, matches))
+}
+
+func TestSearch_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	matches, err := Search(ctx, strings.NewReader("a\na\na\n"), Options{Patterns: []string{"a"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, matches.Next())
+	cancel()
+	assert.False(t, matches.Next())
+	assert.True(t, errors.Is(matches.Err(), context.Canceled))
+}
+
+func TestNew(t *testing.T) {
+	invalidTestData := []Options{
+		{Patterns: []string{"("}, Mode: ExtendedRegexp},
+		{Patterns: []string{"a", `\(`}},
+		{Patterns: []string{"a)|(b"}, Mode: PerlRegexp},
+		{Patterns: []string{"a"}, Mode: Mode(10)},
+		{Patterns: []string{"a"}, Encoding: "latin1"},
+	}
+
+	for _, opts := range invalidTestData {
+		_, err := New(opts)
+		assert.Error(t, err, opts.Patterns)
+	}
+}
@@ -0,0 +1,495 @@
+package grep
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// matcher - интерфейс, проверяющий, соответствует ли строка паттерну.
+type matcher interface {
+	match(s string) bool
+	// Возвращает позиции [начало, конец) всех непересекающихся непустых совпадений в строке.
+	findAll(s string) [][]int
+}
+
+// Поиск фиксированной подстроки с учетом регистра.
+type fixedMatcher struct {
+	pattern string
+}
+
+func (m fixedMatcher) match(s string) bool {
+	return strings.Contains(s, m.pattern)
+}
+
+func (m fixedMatcher) findAll(s string) [][]int {
+	spans := make([][]int, 0)
+	if m.pattern == "" {
+		return spans
+	}
+
+	for pos := 0; ; {
+		i := strings.Index(s[pos:], m.pattern)
+		if i < 0 {
+			return spans
+		}
+		start := pos + i
+		pos = start + len(m.pattern)
+		spans = append(spans, []int{start, pos})
+	}
+}
+
+// Поиск совпадений с регулярным выражением.
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m regexpMatcher) match(s string) bool {
+	return m.re.MatchString(s)
+}
+
+func (m regexpMatcher) findAll(s string) [][]int {
+	spans := make([][]int, 0)
+	for _, span := range m.re.FindAllStringIndex(s, -1) {
+		if span[0] != span[1] {
+			spans = append(spans, span)
+		}
+	}
+
+	return spans
+}
+
+// Поиск совпадений с регулярным выражением, которые являются целыми словами (флаг -w).
+// Регулярное выражение имеет вид (?:^|[^w])(паттерн)(?:[^w]|$), где w - символы, из которых состоят слова,
+// а совпадением считается первая группа.
+type wordMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m wordMatcher) match(s string) bool {
+	return m.re.MatchString(s)
+}
+
+func (m wordMatcher) findAll(s string) [][]int {
+	spans := make([][]int, 0)
+
+	for pos := 0; pos < len(s); {
+		loc := m.re.FindStringSubmatchIndex(s[pos:])
+		if loc == nil {
+			return spans
+		}
+		start, end := pos+loc[2], pos+loc[3]
+
+		// Символ после предыдущего совпадения может быть границей и для следующего, поэтому поиск
+		// продолжается с конца совпадения, а не с конца всего выражения. Начало подстроки s[pos:]
+		// считается началом строки, поэтому граница перед совпадением в начале подстроки проверяется отдельно.
+		if start == pos && pos > 0 {
+			if r, _ := utf8.DecodeLastRuneInString(s[:pos]); isWordRune(r) {
+				_, size := utf8.DecodeRuneInString(s[pos:])
+				pos += size
+				continue
+			}
+		}
+
+		if start == end {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			pos = end + size
+			continue
+		}
+
+		spans = append(spans, []int{start, end})
+		pos = end
+	}
+
+	return spans
+}
+
+// Символы, из которых состоят слова, в синтаксисе регулярных выражений Go.
+const wordRunes = `\p{L}\p{Nd}_`
+
+// Создает matcher для паттернов patterns в соответствии с режимом mode.
+// Строка соответствует паттернам, если она соответствует хотя бы одному из них.
+// Если ignoreCase == true, то регистр игнорируется с помощью флага регулярного выражения (?i),
+// строки и паттерны при этом не изменяются.
+// Если wordRegexp == true, то совпадение должно быть целым словом (флаг -w),
+// если lineRegexp == true - целой строкой (флаг -x). Флаг -x важнее флага -w.
+// Если nullData == true, то строки могут содержать \n, которому соответствуют . и [^...].
+func newMatcher(patterns []string, mode Mode, ignoreCase, wordRegexp, lineRegexp, nullData bool) (matcher, error) {
+	if mode < BasicRegexp || mode > FixedString {
+		return nil, fmt.Errorf("неизвестный режим поиска: %d", mode)
+	}
+
+	// Если паттернов нет (например, передан пустой файл с паттернами), то ни одна строка не совпадает.
+	if mode == FixedString || len(patterns) == 0 {
+		if len(patterns) == 1 && !ignoreCase && !wordRegexp && !lineRegexp {
+			return fixedMatcher{pattern: patterns[0]}, nil
+		}
+		return newACMatcher(patterns, ignoreCase, wordRegexp, lineRegexp), nil
+	}
+
+	trees, err := parsePatterns(patterns, mode, ignoreCase, nullData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Объединение тысяч паттернов в одно регулярное выражение работает медленно,
+	// поэтому, если все паттерны являются фиксированными строками, используется алгоритм Ахо-Корасик.
+	if literals, ok := literalPatterns(trees, ignoreCase); ok && len(literals) > 1 {
+		return newACMatcher(literals, ignoreCase, wordRegexp, lineRegexp), nil
+	}
+
+	exprs := make([]string, 0, len(trees))
+	for _, tree := range trees {
+		exprs = append(exprs, `(?:`+tree.String()+`)`)
+	}
+	expr := strings.Join(exprs, "|")
+
+	switch {
+	case lineRegexp:
+		expr = `^(?:` + expr + `)$`
+	case wordRegexp:
+		expr = `(?:^|[^` + wordRunes + `])(` + expr + `)(?:[^` + wordRunes + `]|$)`
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	// Регулярные выражения POSIX используют семантику leftmost-longest.
+	if mode != PerlRegexp {
+		re.Longest()
+	}
+
+	if wordRegexp && !lineRegexp {
+		return wordMatcher{re: re}, nil
+	}
+	return regexpMatcher{re: re}, nil
+}
+
+// Разбирает каждый паттерн в соответствии с режимом mode.
+// regexp.CompilePOSIX не поддерживает флаги вида (?i), поэтому выражения POSIX разбираются
+// с синтаксисом POSIX и флагом FoldCase, а затем записываются из получившегося дерева в синтаксисе Go.
+// Паттерны разбираются по отдельности, чтобы ошибка в одном из них не могла быть скрыта их объединением,
+// например, паттерн "a)|(b".
+func parsePatterns(patterns []string, mode Mode, ignoreCase, nullData bool) ([]*syntax.Regexp, error) {
+	// Классы Unicode используются в подстановках расширений GNU \w и \W.
+	flags := syntax.POSIX | syntax.UnicodeGroups
+	if mode == PerlRegexp {
+		flags = syntax.Perl
+	}
+	if ignoreCase {
+		flags |= syntax.FoldCase
+	}
+	if nullData {
+		flags |= syntax.DotNL | syntax.ClassNL
+	}
+
+	trees := make([]*syntax.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if mode == BasicRegexp {
+			pattern = basicToExtended(pattern)
+		}
+		if mode != PerlRegexp {
+			var err error
+			if pattern, err = extendedToGo(pattern); err != nil {
+				return nil, err
+			}
+		}
+
+		tree, err := syntax.Parse(pattern, flags)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, replaceWordBoundaries(tree))
+	}
+
+	return trees, nil
+}
+
+// Возвращает строки, если все разобранные паттерны являются фиксированными строками,
+// регистр которых учитывается в соответствии с ignoreCase.
+func literalPatterns(trees []*syntax.Regexp, ignoreCase bool) ([]string, bool) {
+	literals := make([]string, 0, len(trees))
+	for _, tree := range trees {
+		switch {
+		case tree.Op == syntax.OpEmptyMatch:
+			literals = append(literals, "")
+		case tree.Op == syntax.OpLiteral && (tree.Flags&syntax.FoldCase != 0) == ignoreCase:
+			literals = append(literals, string(tree.Rune))
+		default:
+			return nil, false
+		}
+	}
+
+	return literals, true
+}
+
+// Преобразует базовое регулярное выражение POSIX (BRE) в расширенное (ERE).
+// В BRE метасимволами являются \( \) \{ \} \| \+ \?, а ( ) { } | + ? - обычные символы.
+// Символ * в начале выражения или группы, ^ не в начале и $ не в конце также считаются обычными символами.
+func basicToExtended(pattern string) string {
+	var b strings.Builder
+	// Находится ли текущая позиция в начале выражения или группы.
+	atStart := true
+
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+
+		switch {
+		case r == '\\' && i+size < len(pattern):
+			next, nextSize := utf8.DecodeRuneInString(pattern[i+size:])
+			switch next {
+			case '(', '|':
+				b.WriteRune(next)
+				atStart = true
+			case ')', '{', '}', '+', '?':
+				b.WriteRune(next)
+				atStart = false
+			default:
+				b.WriteRune('\\')
+				b.WriteRune(next)
+				atStart = false
+			}
+			i += size + nextSize
+			continue
+
+		case r == '[':
+			end := bracketEnd(pattern, i)
+			b.WriteString(pattern[i:end])
+			i = end
+			atStart = false
+			continue
+
+		case r == '(' || r == ')' || r == '{' || r == '}' || r == '|' || r == '+' || r == '?':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+
+		case r == '*' && atStart:
+			b.WriteString(`\*`)
+
+		case r == '^':
+			if atStart {
+				b.WriteRune(r)
+				i += size
+				// После якоря ^ символ * также считается обычным.
+				continue
+			}
+			b.WriteString(`\^`)
+
+		case r == '$':
+			if isBasicExpressionEnd(pattern, i+size) {
+				b.WriteRune(r)
+			} else {
+				b.WriteString(`\$`)
+			}
+
+		default:
+			b.WriteRune(r)
+		}
+
+		atStart = false
+		i += size
+	}
+
+	return b.String()
+}
+
+// Символ, которым extendedToGo заменяет границы слова \< и \>. Синтаксис POSIX не поддерживает \b,
+// поэтому граница слова записывается этим символом и подставляется в дерево после разбора (см. replaceWordBoundaries).
+// Это несимвол Unicode, который не встречается в тексте.
+const wordBoundaryRune = '\uFDD0'
+
+// Преобразует расширенное регулярное выражение POSIX (ERE) к виду, который разбирает пакет regexp/syntax:
+//   - интервал {,n} заменяется на {0,n}, как в GNU grep, иначе syntax.Parse считает его обычными символами;
+//   - границы слова \< и \> заменяются на wordBoundaryRune, то есть в итоге на \b. В regexp нет отдельных границ
+//     начала и конца слова, а \b учитывает только символы ASCII, поэтому \< допускается только перед латинской буквой,
+//     цифрой или _, а \> - только после них: в этом случае \b совпадает с нужной границей.
+//     В остальных случаях, а также при повторении \>, возвращается ошибка;
+//   - расширения GNU \w, \W, \s и \S заменяются на классы символов (см. gnuClasses);
+//   - в скобочных выражениях символ \ является обычным символом, поэтому он экранируется (см. bracketToGo).
+func extendedToGo(pattern string) (string, error) {
+	var b strings.Builder
+	// Является ли предыдущий символ выражения латинской буквой, цифрой или _.
+	prevWord := false
+
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+
+		switch {
+		case r == '\\' && i+size < len(pattern):
+			next, nextSize := utf8.DecodeRuneInString(pattern[i+size:])
+			switch {
+			case next == '<':
+				if after, _ := utf8.DecodeRuneInString(pattern[i+size+nextSize:]); !isASCIIWordRune(after) {
+					return "", fmt.Errorf(`\< поддерживается только перед латинской буквой, цифрой или _: %s`, pattern)
+				}
+				b.WriteRune(wordBoundaryRune)
+			case next == '>':
+				after, _ := utf8.DecodeRuneInString(pattern[i+size+nextSize:])
+				if !prevWord || strings.ContainsRune("*+?{", after) {
+					return "", fmt.Errorf(`\> поддерживается только после латинской буквы, цифры или _: %s`, pattern)
+				}
+				b.WriteRune(wordBoundaryRune)
+			case gnuClasses[next] != "":
+				b.WriteString(gnuClasses[next])
+			case next == 'p' || next == 'P':
+				// Классы Unicode разрешены при разборе только для подстановки \w и \W.
+				return "", fmt.Errorf(`недопустимая escape-последовательность \%c: %s`, next, pattern)
+			default:
+				b.WriteString(pattern[i : i+size+nextSize])
+			}
+			i += size + nextSize
+			prevWord = false
+			continue
+
+		case r == '[':
+			end := bracketEnd(pattern, i)
+			b.WriteString(bracketToGo(pattern[i:end]))
+			i = end
+			prevWord = false
+			continue
+
+		case r == wordBoundaryRune:
+			return "", fmt.Errorf("недопустимый символ %U в паттерне", r)
+
+		case r == '{' && strings.HasPrefix(pattern[i+size:], ","):
+			if n := strings.IndexByte(pattern[i+size+1:], '}'); n > 0 && isDigits(pattern[i+size+1:i+size+1+n]) {
+				b.WriteString("{0")
+			} else {
+				b.WriteRune(r)
+			}
+
+		default:
+			b.WriteRune(r)
+		}
+
+		prevWord = isASCIIWordRune(r)
+		i += size
+	}
+
+	return b.String(), nil
+}
+
+// Классы символов, которыми заменяются расширения GNU \w, \W, \s и \S.
+// Символы слова те же, что и при флаге -w.
+var gnuClasses = map[rune]string{
+	'w': `[` + wordRunes + `]`,
+	'W': `[^` + wordRunes + `]`,
+	's': `[[:space:]]`,
+	'S': `[^[:space:]]`,
+}
+
+// Преобразует скобочное выражение POSIX к синтаксису Go: в POSIX символ \ внутри [...] обычный,
+// а в Go он экранирует следующий символ. Классы вида [:alpha:] не изменяются.
+func bracketToGo(bracket string) string {
+	var b strings.Builder
+	for i := 0; i < len(bracket); i++ {
+		if i > 0 && (strings.HasPrefix(bracket[i:], "[:") || strings.HasPrefix(bracket[i:], "[=") || strings.HasPrefix(bracket[i:], "[.")) {
+			closing := bracket[i+1:i+2] + "]"
+			if end := strings.Index(bracket[i+2:], closing); end >= 0 {
+				b.WriteString(bracket[i : i+2+end+len(closing)])
+				i += 1 + end + len(closing)
+				continue
+			}
+		}
+		if bracket[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(bracket[i])
+	}
+
+	return b.String()
+}
+
+// Заменяет в дереве символы wordBoundaryRune на границы слова \b.
+func replaceWordBoundaries(tree *syntax.Regexp) *syntax.Regexp {
+	for i, sub := range tree.Sub {
+		tree.Sub[i] = replaceWordBoundaries(sub)
+	}
+	if tree.Op != syntax.OpLiteral || !containsRune(tree.Rune, wordBoundaryRune) {
+		return tree
+	}
+
+	// Литерал делится на части между границами слова.
+	concat := &syntax.Regexp{Op: syntax.OpConcat, Flags: tree.Flags}
+	start := 0
+	for i := 0; i <= len(tree.Rune); i++ {
+		if i < len(tree.Rune) && tree.Rune[i] != wordBoundaryRune {
+			continue
+		}
+		if i > start {
+			concat.Sub = append(concat.Sub, &syntax.Regexp{Op: syntax.OpLiteral, Flags: tree.Flags, Rune: tree.Rune[start:i]})
+		}
+		if i < len(tree.Rune) {
+			concat.Sub = append(concat.Sub, &syntax.Regexp{Op: syntax.OpWordBoundary})
+		}
+		start = i + 1
+	}
+	if len(concat.Sub) == 1 {
+		return concat.Sub[0]
+	}
+	return concat
+}
+
+func containsRune(runes []rune, r rune) bool {
+	for _, c := range runes {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Проверяет, является ли символ латинской буквой, цифрой или _, то есть символом слова для \b.
+func isASCIIWordRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// Проверяет, состоит ли непустая строка только из цифр ASCII.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Проверяет, заканчивается ли выражение или группа в позиции i.
+func isBasicExpressionEnd(pattern string, i int) bool {
+	rest := pattern[i:]
+	return rest == "" || strings.HasPrefix(rest, `\)`) || strings.HasPrefix(rest, `\|`)
+}
+
+// Возвращает позицию, следующую за скобочным выражением [...], которое начинается в позиции start.
+// Символ ] сразу после [ или [^ считается частью выражения, классы вида [:alpha:] пропускаются целиком.
+// Если скобочное выражение не закрыто, возвращается длина паттерна.
+func bracketEnd(pattern string, start int) int {
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+
+	for i < len(pattern) {
+		switch {
+		case strings.HasPrefix(pattern[i:], "[:") || strings.HasPrefix(pattern[i:], "[=") || strings.HasPrefix(pattern[i:], "[."):
+			closing := pattern[i+1:i+2] + "]"
+			end := strings.Index(pattern[i+2:], closing)
+			if end < 0 {
+				return len(pattern)
+			}
+			i += 2 + end + len(closing)
+		case pattern[i] == ']':
+			return i + 1
+		default:
+			i++
+		}
+	}
+
+	return len(pattern)
+}
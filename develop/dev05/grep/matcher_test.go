@@ -0,0 +1,117 @@
+package grep
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMatcher(t *testing.T) {
+	testData := []struct {
+		pattern  string
+		mode     Mode
+		line     string
+		expected [][]int
+	}{
+		{pattern: "a{,2}r", mode: ExtendedRegexp, line: "bar", expected: [][]int{{1, 3}}},
+		{pattern: "a{,2}r", mode: ExtendedRegexp, line: "baaar", expected: [][]int{{2, 5}}},
+		{pattern: `a\{,2\}r`, mode: BasicRegexp, line: "bar", expected: [][]int{{1, 3}}},
+		{pattern: "a{,2}r", mode: BasicRegexp, line: "a{,2}r", expected: [][]int{{0, 6}}},
+		{pattern: "a{,x}", mode: ExtendedRegexp, line: "a{,x}", expected: [][]int{{0, 5}}},
+		{pattern: "[{,2}]", mode: ExtendedRegexp, line: "{", expected: [][]int{{0, 1}}},
+		{pattern: `\<ab`, mode: BasicRegexp, line: "cab ab", expected: [][]int{{4, 6}}},
+		{pattern: `ab\>`, mode: BasicRegexp, line: "abc ab", expected: [][]int{{4, 6}}},
+		{pattern: `\<ab\>`, mode: ExtendedRegexp, line: "abc ab", expected: [][]int{{4, 6}}},
+		{pattern: `\<ab|\<cd`, mode: ExtendedRegexp, line: "xab cd", expected: [][]int{{4, 6}}},
+		{pattern: `x\(\<ab\)*`, mode: BasicRegexp, line: "xab x ab", expected: [][]int{{0, 1}, {4, 5}}},
+		{pattern: `a\<`, mode: BasicRegexp, line: "a\\<", expected: nil},
+		{pattern: `a\>*`, mode: ExtendedRegexp, line: "a", expected: nil},
+		// В скобочных выражениях POSIX символ \ обычный.
+		{pattern: `a[\]b`, mode: BasicRegexp, line: `a\b`, expected: [][]int{{0, 3}}},
+		{pattern: `a[\]b`, mode: ExtendedRegexp, line: `a\b`, expected: [][]int{{0, 3}}},
+		{pattern: `[a\.]`, mode: ExtendedRegexp, line: `x\`, expected: [][]int{{1, 2}}},
+		{pattern: `[a\.]+`, mode: ExtendedRegexp, line: `xa.\`, expected: [][]int{{1, 4}}},
+		{pattern: `[^\]`, mode: ExtendedRegexp, line: `\a`, expected: [][]int{{1, 2}}},
+		{pattern: `[\[:digit:]]+`, mode: ExtendedRegexp, line: `x1\2`, expected: [][]int{{1, 4}}},
+		{pattern: `[\n]`, mode: ExtendedRegexp, line: `\n`, expected: [][]int{{0, 1}, {1, 2}}},
+		// Расширения GNU \w, \W, \s и \S.
+		{pattern: `\w\+`, mode: BasicRegexp, line: "-ab_1 где", expected: [][]int{{1, 5}, {6, 12}}},
+		{pattern: `\W+`, mode: ExtendedRegexp, line: "ab, cd", expected: [][]int{{2, 4}}},
+		{pattern: `a\sb`, mode: ExtendedRegexp, line: "a\tb a b", expected: [][]int{{0, 3}, {4, 7}}},
+		{pattern: `\S\+`, mode: BasicRegexp, line: " ab ", expected: [][]int{{1, 3}}},
+		{pattern: `\pL`, mode: ExtendedRegexp, line: "a", expected: nil},
+	}
+
+	for _, data := range testData {
+		m, err := newMatcher([]string{data.pattern}, data.mode, false, false, false, false)
+		if data.expected == nil {
+			assert.Error(t, err, data.pattern)
+			continue
+		}
+		if !assert.NoError(t, err, data.pattern) {
+			continue
+		}
+
+		assert.Equal(t, data.expected, m.findAll(data.line), data.pattern)
+	}
+
+	// \< и \> рядом с символом, который не является латинской буквой, цифрой или _, не поддерживаются,
+	// потому что \b не различает начало и конец слова.
+	for _, pattern := range []string{`.\>`, `б\>`, `\<.`, `\<б`} {
+		_, err := newMatcher([]string{pattern}, BasicRegexp, false, false, false, false)
+		assert.Error(t, err, pattern)
+	}
+}
+
+// Возвращает n различных случайных слов длины size из букв a-j.
+func randomWords(r *rand.Rand, n, size int) []string {
+	set := make(map[string]bool, n)
+	words := make([]string, 0, n)
+	for len(words) < n {
+		var b strings.Builder
+		for i := 0; i < size; i++ {
+			b.WriteByte(byte('a' + r.Intn(10)))
+		}
+		if !set[b.String()] {
+			set[b.String()] = true
+			words = append(words, b.String())
+		}
+	}
+
+	return words
+}
+
+// Скорость поиска при большом количестве фиксированных паттернов (флаги -f и -F)
+// не должна зависеть от количества паттернов.
+func BenchmarkNewMatcher_manyPatterns(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	lines := make([]string, 1000)
+	size := 0
+	for i := range lines {
+		lines[i] = strings.Join(randomWords(r, 10, 6), " ")
+		size += len(lines[i])
+	}
+
+	for _, n := range []int{10, 1000, 10000} {
+		patterns := randomWords(r, n, 6)
+
+		for _, mode := range []Mode{FixedString, BasicRegexp} {
+			m, err := newMatcher(patterns, mode, false, true, false, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.Run(fmt.Sprintf("mode=%d/patterns=%d", mode, n), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					for _, line := range lines {
+						m.match(line)
+					}
+				}
+			})
+		}
+	}
+}
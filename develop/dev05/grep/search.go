@@ -0,0 +1,264 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Количество байт в начале данных, по которым определяется, являются ли данные бинарными.
+const binaryCheckSize = 8192
+
+// Строка, прочитанная до выбранной строки.
+// matched - соответствует ли строка паттернам.
+type beforeData struct {
+	match   Match
+	matched bool
+}
+
+// Кольцевой буфер фиксированной емкости, в котором хранятся последние строки до выбранной строки.
+// Позволяет возвращать строки до совпадения, не храня в памяти все прочитанные данные.
+// Память под строки выделяется по мере записи, поэтому она зависит от количества строк в буфере,
+// а не от емкости, которую задает пользователь (флаги -B и -C).
+// data - строки буфера.
+// capacity - емкость буфера.
+// start - индекс самой старой строки в буфере.
+// size - количество строк в буфере.
+type beforeDataRing struct {
+	data     []beforeData
+	capacity int
+	start    int
+	size     int
+}
+
+// Конструктор структуры beforeDataRing.
+func newBeforeDataRing(capacity int) *beforeDataRing {
+	return &beforeDataRing{
+		capacity: capacity,
+	}
+}
+
+// Write - записывает строку в буфер. Если буфер заполнен, то самая старая строка перезаписывается.
+func (r *beforeDataRing) Write(data beforeData) {
+	if r.capacity <= 0 {
+		return
+	}
+
+	if r.size < r.capacity {
+		// Пока буфер не заполнен до емкости, строки не перезаписываются и start равен 0,
+		// поэтому новая строка добавляется в конец data, если в нем нет места.
+		if r.size < len(r.data) {
+			r.data[(r.start+r.size)%len(r.data)] = data
+		} else {
+			r.data = append(r.data, data)
+		}
+		r.size++
+		return
+	}
+
+	r.data[r.start] = data
+	r.start = (r.start + 1) % len(r.data)
+}
+
+// Flush - передает все строки буфера в порядке записи в функцию print и очищает буфер.
+func (r *beforeDataRing) Flush(print func(data beforeData)) {
+	for i := 0; i < r.size; i++ {
+		print(r.data[(r.start+i)%len(r.data)])
+	}
+
+	r.start = 0
+	r.size = 0
+}
+
+// Matches - поток строк, найденных при поиске: выбранных строк и строк до и после них.
+// Строки возвращаются в порядке чтения, каждая строка возвращается не более одного раза.
+// Данные читаются построчно по мере вызова Next, поэтому объем используемой памяти не зависит
+// от объема данных: в памяти хранятся только Options.Before строк до выбранной строки.
+// Длина строки не ограничена.
+//
+// Использование аналогично bufio.Scanner:
+//
+//	for matches.Next() {
+//		m := matches.Match()
+//		...
+//	}
+//	err := matches.Err()
+type Matches struct {
+	ctx      context.Context
+	searcher *Searcher
+	reader   *bufio.Reader
+	// Разделитель строк: \n или нулевой байт при Options.NullData.
+	delim byte
+	// Являются ли данные бинарными.
+	binary bool
+
+	// Текущая строка.
+	match Match
+	// Строки, которые нужно вернуть перед чтением следующей строки.
+	pending []Match
+	// Буфер, в который записываются строки до выбранной строки.
+	before *beforeDataRing
+	// Количество строк после выбранной строки, которые нужно вернуть.
+	afterCount int
+	// Количество выбранных строк.
+	selectedCount int
+	// Номер последней прочитанной строки.
+	lineNum int
+	// Смещение начала следующей строки в байтах.
+	offset int64
+	// Завершен ли поиск.
+	done bool
+	err  error
+}
+
+// Конструктор структуры Matches.
+func newMatches(ctx context.Context, s *Searcher, r io.Reader) *Matches {
+	// Бинарность данных проверяется после перекодирования, так как текст в UTF-16 содержит нулевые байты.
+	reader := bufio.NewReaderSize(decode(bufio.NewReader(r), s.encoding), binaryCheckSize)
+	m := &Matches{
+		ctx:      ctx,
+		searcher: s,
+		reader:   reader,
+		delim:    '\n',
+		before:   newBeforeDataRing(s.opts.Before),
+	}
+	if s.opts.NullData {
+		m.delim = 0
+	} else {
+		m.binary = isBinary(reader)
+	}
+
+	return m
+}
+
+// Читает следующую строку до разделителя m.delim или до конца данных (EOF).
+// Возвращает строку без разделителя (и без \r перед \n) и количество прочитанных байт вместе с ними.
+// Если данные закончились, то возвращает ошибку io.EOF.
+func (m *Matches) readLine() (string, int, error) {
+	line, err := m.reader.ReadString(m.delim)
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", 0, err
+	}
+
+	n := len(line)
+	line = strings.TrimSuffix(line, string(m.delim))
+	if m.delim == '\n' {
+		line = strings.TrimSuffix(line, "\r")
+	}
+
+	return line, n, nil
+}
+
+// Binary - возвращает true, если данные являются бинарными, то есть в первом прочитанном блоке данных
+// встречается нулевой байт. Поиск в бинарных данных происходит так же, как и в текстовых.
+func (m *Matches) Binary() bool {
+	return m.binary
+}
+
+// Next - переходит к следующей найденной строке, которая затем доступна через Match.
+// Возвращает false, когда данные закончились, достигнуто ограничение Options.MaxCount,
+// контекст ctx отменен или произошла ошибка чтения.
+func (m *Matches) Next() bool {
+	opts := m.searcher.opts
+
+	for len(m.pending) == 0 {
+		if m.done {
+			return false
+		}
+		if err := m.ctx.Err(); err != nil {
+			m.err = err
+			m.done = true
+			return false
+		}
+		text, n, err := m.readLine()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				m.err = err
+			}
+			m.done = true
+			return false
+		}
+
+		m.lineNum++
+		line := Match{
+			LineNum: m.lineNum,
+			Offset:  m.offset,
+			Line:    text,
+		}
+		m.offset += int64(n)
+		matched := m.searcher.matcher.match(line.Line)
+
+		// Если достигнуто максимальное количество выбранных строк, то возвращаются только
+		// оставшиеся строки после последней выбранной строки.
+		if opts.MaxCount > 0 && m.selectedCount >= opts.MaxCount {
+			if m.afterCount == 0 {
+				m.done = true
+				return false
+			}
+			m.afterCount--
+			m.push(beforeData{match: line, matched: matched}, true)
+			continue
+		}
+
+		// Если указан флаг исключения, то выбираются строки, которые не являются совпадением.
+		if matched != opts.Invert {
+			m.selectedCount++
+			// Сначала возвращаются строки до выбранной строки, которые еще не были возвращены.
+			m.before.Flush(func(data beforeData) {
+				m.push(data, true)
+			})
+			m.push(beforeData{match: line, matched: matched}, false)
+			m.afterCount = opts.After
+			continue
+		}
+
+		// Если есть строки после выбранной строки, которые нужно вернуть, то возвращается текущая строка,
+		// иначе она запоминается как строка до возможной следующей выбранной строки.
+		if m.afterCount > 0 {
+			m.afterCount--
+			m.push(beforeData{match: line, matched: matched}, true)
+		} else {
+			m.before.Write(beforeData{match: line, matched: matched})
+		}
+	}
+
+	m.match = m.pending[0]
+	m.pending = m.pending[1:]
+	return true
+}
+
+// Добавляет строку в очередь строк, которые нужно вернуть.
+// Позиции совпадений ищутся только для тех строк, которые будут возвращены.
+func (m *Matches) push(data beforeData, context bool) {
+	data.match.Context = context
+	if data.matched {
+		data.match.Spans = m.searcher.matcher.findAll(data.match.Line)
+	}
+
+	m.pending = append(m.pending, data.match)
+}
+
+// Match - возвращает текущую строку.
+func (m *Matches) Match() Match {
+	return m.match
+}
+
+// Err - возвращает ошибку, из-за которой поиск был прерван, или nil, если данные закончились
+// или достигнуто ограничение Options.MaxCount.
+func (m *Matches) Err() error {
+	return m.err
+}
+
+// Проверяет, является ли содержимое бинарным. Данные считаются бинарными, если
+// в первом прочитанном из reader блоке (не более binaryCheckSize байт) встречается нулевой байт.
+// Ожидается только первое чтение, поэтому при вводе данных в stdin поиск не блокируется
+// до накопления binaryCheckSize байт. Данные из reader при этом не вычитываются.
+func isBinary(reader *bufio.Reader) bool {
+	// Заполняем буфер reader одним чтением.
+	reader.Peek(1)
+	data, _ := reader.Peek(reader.Buffered())
+	return bytes.IndexByte(data, 0) >= 0
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+
+	"github.com/hurstcain/tasks_l2/develop/dev05/grep"
+)
+
+// Событие вывода в формате JSON (флаг --json). Каждое событие выводится отдельной строкой.
+// type - тип события: begin, match, context или end.
+type jsonEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Строка или имя файла в выводе JSON. Данные в кодировке UTF-8 выводятся как {"text": "..."},
+// остальные данные - как {"bytes": "..."} в кодировке base64, чтобы они не искажались.
+type jsonText string
+
+func (t jsonText) MarshalJSON() ([]byte, error) {
+	if utf8.ValidString(string(t)) {
+		return json.Marshal(struct {
+			Text string `json:"text"`
+		}{string(t)})
+	}
+
+	return json.Marshal(struct {
+		Bytes []byte `json:"bytes"`
+	}{[]byte(t)})
+}
+
+// Событие begin - начало вывода строк файла.
+type jsonBegin struct {
+	Path jsonText `json:"path"`
+}
+
+// Совпадение в строке: совпадающая часть строки и ее позиция [start, end) в байтах.
+type jsonSubmatch struct {
+	Match jsonText `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+// События match и context - выбранная строка и строка до или после нее.
+type jsonLine struct {
+	Path           jsonText       `json:"path"`
+	Line           jsonText       `json:"line"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int64          `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+// Статистика поиска в файле.
+// matched_lines - количество выбранных строк, matches - количество совпадений в них.
+type jsonStats struct {
+	MatchedLines int `json:"matched_lines"`
+	Matches      int `json:"matches"`
+}
+
+// Событие end - конец вывода строк файла.
+type jsonEnd struct {
+	Path   jsonText  `json:"path"`
+	Binary bool      `json:"binary"`
+	Stats  jsonStats `json:"stats"`
+}
+
+// jsonWriter - выводит в w события в формате JSON для строк одного файла.
+// Событие begin выводится перед первой строкой файла, поэтому файлы без совпадений в выводе отсутствуют.
+type jsonWriter struct {
+	w     io.Writer
+	path  jsonText
+	begun bool
+	stats jsonStats
+}
+
+// Конструктор структуры jsonWriter. name - имя файла.
+func newJSONWriter(w io.Writer, name string) *jsonWriter {
+	return &jsonWriter{
+		w:    w,
+		path: jsonText(name),
+	}
+}
+
+// Выводит событие с типом eventType и данными data.
+func (j *jsonWriter) write(eventType string, data interface{}) {
+	b, err := json.Marshal(jsonEvent{Type: eventType, Data: data})
+	if err != nil {
+		return
+	}
+	j.w.Write(append(b, '\n'))
+}
+
+// Выводит событие begin, если оно еще не было выведено.
+func (j *jsonWriter) begin() {
+	if j.begun {
+		return
+	}
+	j.begun = true
+	j.write("begin", jsonBegin{Path: j.path})
+}
+
+// Write - выводит найденную строку m как событие match или context.
+func (j *jsonWriter) Write(m grep.Match) {
+	j.begin()
+
+	submatches := make([]jsonSubmatch, 0, len(m.Spans))
+	for _, span := range m.Spans {
+		submatches = append(submatches, jsonSubmatch{
+			Match: jsonText(m.Line[span[0]:span[1]]),
+			Start: span[0],
+			End:   span[1],
+		})
+	}
+
+	eventType := "context"
+	if !m.Context {
+		eventType = "match"
+		j.stats.MatchedLines++
+		j.stats.Matches += len(submatches)
+	}
+
+	j.write(eventType, jsonLine{
+		Path:           j.path,
+		Line:           jsonText(m.Line),
+		LineNumber:     m.LineNum,
+		AbsoluteOffset: m.Offset,
+		Submatches:     submatches,
+	})
+}
+
+// End - выводит событие end, если для файла было выведено событие begin.
+// Для бинарных данных строки не выводятся, поэтому при matchesCount > 0 выводятся оба события.
+func (j *jsonWriter) End(binary bool, matchesCount int) {
+	if binary && matchesCount > 0 {
+		j.begin()
+		j.stats.MatchedLines = matchesCount
+	}
+	if !j.begun {
+		return
+	}
+
+	j.write("end", jsonEnd{
+		Path:   j.path,
+		Binary: binary,
+		Stats:  j.stats,
+	})
+}
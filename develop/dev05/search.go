@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// Результат поиска совпадений в одном файле.
+// matchesCount - количество совпадений.
+// err - ошибка, возникшая при поиске.
+type searchResult struct {
+	matchesCount int
+	err          error
+}
+
+// Задача поиска совпадений в файле name. Строки для вывода записываются в output,
+// результат поиска - в канал result.
+type searchJob struct {
+	name   string
+	output *jobOutput
+	result chan *searchResult
+}
+
+// Максимальный размер вывода одного файла, который хранится в памяти, пока выводятся более ранние файлы.
+const maxBufferedOutput = 1 << 20
+
+// Вывод поиска в одном файле при поиске в нескольких файлах одновременно.
+// Пока выводятся результаты более ранних файлов, строки накапливаются в буфере, а когда буфер
+// заполнен, запись блокируется. Когда файл становится первым в очереди вывода (метод start),
+// буфер выводится, и дальнейшие строки записываются сразу в поток вывода.
+type jobOutput struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// Поток вывода. Равен nil, пока файл не стал первым в очереди вывода.
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func newJobOutput() *jobOutput {
+	o := new(jobOutput)
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.w == nil && o.buf.Len() >= maxBufferedOutput {
+		o.cond.Wait()
+	}
+	if o.w == nil {
+		return o.buf.Write(p)
+	}
+	return o.w.Write(p)
+}
+
+// Выводит накопленные строки в w и переключает запись на w.
+func (o *jobOutput) start(w io.Writer) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, err := o.buf.WriteTo(w)
+	o.w = w
+	o.cond.Broadcast()
+	return err
+}
+
+// Осуществляет поиск совпадений во всех файлах и записывает результат в w.
+// Возвращает общее количество совпадений.
+// Если g.flags.Jobs > 1, то поиск происходит одновременно в g.flags.Jobs файлах,
+// но результаты записываются в w в том же порядке, в котором файлы были найдены,
+// поэтому вывод не зависит от количества горутин. Строки первого в очереди файла выводятся сразу,
+// а строки следующих файлов накапливаются в буферах ограниченного размера (см. jobOutput).
+// При флаге -q поиск всегда происходит в одном потоке и прекращается после первого совпадения.
+func (g Grep) searchFiles(ctx context.Context, w io.Writer) int {
+	if g.flags.Follow {
+		return g.followFiles(ctx, w)
+	}
+
+	matchesCount := 0
+
+	// При поиске в одном потоке строки выводятся сразу, без буферизации.
+	if g.flags.Jobs <= 1 || g.flags.Quiet {
+		g.walkFiles(func(name string) bool {
+			n, err := g.fileSearch(ctx, name, w)
+			if err != nil {
+				g.errs.Report(err)
+			}
+			matchesCount += n
+			return !g.flags.Quiet || matchesCount == 0
+		})
+		return matchesCount
+	}
+
+	// Канал задач, из которого читают горутины-исполнители.
+	jobs := make(chan searchJob)
+	// Канал задач в порядке их создания. Его емкость ограничивает количество файлов,
+	// вывод которых одновременно хранится в памяти.
+	ordered := make(chan searchJob, g.flags.Jobs)
+
+	go func() {
+		g.walkFiles(func(name string) bool {
+			job := searchJob{
+				name:   name,
+				output: newJobOutput(),
+				result: make(chan *searchResult, 1),
+			}
+			ordered <- job
+			jobs <- job
+			return true
+		})
+		close(jobs)
+		close(ordered)
+	}()
+
+	for i := 0; i < g.flags.Jobs; i++ {
+		go func() {
+			for job := range jobs {
+				result := new(searchResult)
+				result.matchesCount, result.err = g.fileSearch(ctx, job.name, job.output)
+				job.result <- result
+			}
+		}()
+	}
+
+	// Выводим результаты в порядке создания задач.
+	for job := range ordered {
+		if err := job.output.start(w); err != nil {
+			g.errs.Report(err)
+		}
+		result := <-job.result
+		if result.err != nil {
+			g.errs.Report(result.err)
+		}
+		matchesCount += result.matchesCount
+	}
+
+	return matchesCount
+}
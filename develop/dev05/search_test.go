@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hurstcain/tasks_l2/develop/dev05/grep"
+	"github.com/stretchr/testify/assert"
+)
+
+// Вывод файлов, который не помещается в буферы, при поиске в нескольких файлах одновременно
+// совпадает с выводом при поиске в одном потоке.
+func TestGrep_searchFilesLargeOutput(t *testing.T) {
+	root := t.TempDir()
+	line := "x" + strings.Repeat(".", 98) + "\n"
+	for i := 0; i < 4; i++ {
+		content := strings.Repeat(line, 2*maxBufferedOutput/len(line))
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("file%d", i)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(jobs string) string {
+		var stdout, stderr bytes.Buffer
+		g, err := NewGrep([]string{"-r", "-j", jobs, "-n", "x", root}, strings.NewReader(""), &stdout, &stderr)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		assert.Equal(t, exitMatch, g.Search(context.Background()))
+		assert.Empty(t, stderr.String())
+		return stdout.String()
+	}
+
+	expected := search("1")
+	assert.Equal(t, expected, search("4"))
+}
+
+func TestJobOutput(t *testing.T) {
+	o := newJobOutput()
+	chunk := bytes.Repeat([]byte("a"), maxBufferedOutput)
+
+	// Пока буфер заполнен, запись блокируется.
+	written := make(chan struct{})
+	go func() {
+		o.Write(chunk)
+		o.Write([]byte("b"))
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("запись в заполненный буфер не заблокирована")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	var w bytes.Buffer
+	assert.NoError(t, o.start(&w))
+	<-written
+	assert.Equal(t, string(chunk)+"b", w.String())
+	assert.Zero(t, o.buf.Len())
+}
+
+// Создает во временной директории дерево из dirs директорий по files файлов в каждой,
+// в каждом файле lines строк. Возвращает путь к дереву и его размер в байтах.
+func createBenchTree(b *testing.B, dirs, files, lines int) (string, int64) {
+	root := b.TempDir()
+	var size int64
+
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", d))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+
+		for f := 0; f < files; f++ {
+			var content strings.Builder
+			for l := 0; l < lines; l++ {
+				if l%100 == 0 {
+					fmt.Fprintf(&content, "%d: ERROR request %d failed with code %d\n", l, l*f, 500+l%3)
+					continue
+				}
+				fmt.Fprintf(&content, "%d: INFO request %d served in %dms by worker %d\n", l, l*f, l%250, d)
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.log", f)), []byte(content.String()), 0644); err != nil {
+				b.Fatal(err)
+			}
+			size += int64(content.Len())
+		}
+	}
+
+	return root, size
+}
+
+// Сравнение скорости поиска в большом дереве файлов при разном количестве горутин (флаг -j).
+func BenchmarkGrep_searchFiles(b *testing.B) {
+	root, size := createBenchTree(b, 20, 25, 4000)
+
+	opts := grep.Options{
+		Patterns: []string{`ERROR.*code 50[12]`},
+		Mode:     grep.ExtendedRegexp,
+	}
+	s, err := grep.New(opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, jobs := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("j=%d", jobs), func(b *testing.B) {
+			g := Grep{
+				searcher: s,
+				errs:     newErrorLog(io.Discard, false),
+				flags: grepFlags{
+					Options:      opts,
+					FileNames:    []string{root},
+					Recursive:    true,
+					WithFileName: true,
+					LineNum:      true,
+					Jobs:         jobs,
+				},
+			}
+
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.searchFiles(context.Background(), io.Discard)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -8,118 +9,156 @@ import (
 
 import "github.com/hurstcain/tasks_l2/develop/dev11/internal/model"
 
+// Errors returned by Cache. They are wrapped with details, so use errors.Is to check them.
+var (
+	ErrEventExists   = errors.New("event with this id already exists")
+	ErrEventNotFound = errors.New("event with this id doesn't exist")
+	ErrEventsLimit   = errors.New("events limit is reached")
+	ErrNoEvents      = errors.New("no events")
+)
+
 type Cache struct {
-	events []model.Event
+	events    []model.Event
+	archive   []model.Event
+	maxEvents int
+	stats     RetentionStats
+	stop      chan struct{}
 	sync.RWMutex
 }
 
 func NewCache() *Cache {
 	return &Cache{
-		events: make([]model.Event, 0),
+		events:  make([]model.Event, 0),
+		archive: make([]model.Event, 0),
 	}
 }
 
 func (c *Cache) CreateEvent(event model.Event) error {
+	c.Lock()
+	defer c.Unlock()
+
 	if _, exists := c.checkEventIdExistence(event.EventId); exists {
-		return fmt.Errorf("event with this id already exists")
+		return ErrEventExists
 	}
 
-	c.Lock()
+	if c.checkArchivedEventIdExistence(event.EventId) {
+		return fmt.Errorf("%w in archive", ErrEventExists)
+	}
+
+	if c.maxEvents > 0 && len(c.events) >= c.maxEvents {
+		return ErrEventsLimit
+	}
 	c.events = append(c.events, event)
-	c.Unlock()
 
 	return nil
 }
 
 func (c *Cache) UpdateEvent(event model.Event) error {
+	c.Lock()
+	defer c.Unlock()
+
 	i, exists := c.checkEventIdExistence(event.EventId)
 	if !exists {
-		return fmt.Errorf("event with this id doesn't exist")
+		return ErrEventNotFound
 	}
-
-	c.Lock()
 	c.events[i] = event
-	c.Unlock()
 
 	return nil
 }
 
 func (c *Cache) DeleteEvent(eventId string) error {
+	c.Lock()
+	defer c.Unlock()
+
 	i, exists := c.checkEventIdExistence(eventId)
 	if !exists {
-		return fmt.Errorf("event with this id doesn't exist")
+		return ErrEventNotFound
 	}
-
-	c.Lock()
 	c.events = append(c.events[:i], c.events[i+1:]...)
-	c.Unlock()
 
 	return nil
 }
 
-func (c *Cache) GetEventsForDay(userId string, date time.Time) ([]model.Event, error) {
-	eventsForDay := make([]model.Event, 0)
-
-	c.RLock()
-	for _, event := range c.events {
-		if event.UserId == userId && event.Date == date {
-			eventsForDay = append(eventsForDay, event)
-		}
-	}
-	c.RUnlock()
+func (c *Cache) GetEventsForDay(userId string, date time.Time, includeArchived bool) ([]model.Event, error) {
+	eventsForDay := c.filterEvents(func(event model.Event) bool {
+		return event.UserId == userId && event.Date == date
+	}, includeArchived)
 
 	if areEventsEmpty(eventsForDay) {
-		return nil, fmt.Errorf("no events for this day")
+		return nil, fmt.Errorf("%w for this day", ErrNoEvents)
 	}
 
 	return eventsForDay, nil
 }
 
-func (c *Cache) GetEventsForWeek(userId string, date time.Time) ([]model.Event, error) {
-	eventsForWeek := make([]model.Event, 0)
+func (c *Cache) GetEventsForWeek(userId string, date time.Time, includeArchived bool) ([]model.Event, error) {
 	year, week := date.ISOWeek()
 
-	c.RLock()
-	for _, event := range c.events {
+	eventsForWeek := c.filterEvents(func(event model.Event) bool {
 		eventYear, eventWeek := event.Date.ISOWeek()
-		if event.UserId == userId && eventYear == year && eventWeek == week {
-			eventsForWeek = append(eventsForWeek, event)
-		}
-	}
-	c.RUnlock()
+		return event.UserId == userId && eventYear == year && eventWeek == week
+	}, includeArchived)
 
 	if areEventsEmpty(eventsForWeek) {
-		return nil, fmt.Errorf("no events for this week")
+		return nil, fmt.Errorf("%w for this week", ErrNoEvents)
 	}
 
 	return eventsForWeek, nil
 }
 
-func (c *Cache) GetEventsForMonth(userId string, date time.Time) ([]model.Event, error) {
-	eventsForYear := make([]model.Event, 0)
+func (c *Cache) GetEventsForMonth(userId string, date time.Time, includeArchived bool) ([]model.Event, error) {
 	year := date.Year()
 	month := date.Month()
 
-	c.RLock()
-	for _, event := range c.events {
+	eventsForYear := c.filterEvents(func(event model.Event) bool {
 		eventYear := event.Date.Year()
 		eventMonth := event.Date.Month()
-		if event.UserId == userId && eventYear == year && eventMonth == month {
-			eventsForYear = append(eventsForYear, event)
-		}
-	}
-	c.RUnlock()
+		return event.UserId == userId && eventYear == year && eventMonth == month
+	}, includeArchived)
 
 	if areEventsEmpty(eventsForYear) {
-		return nil, fmt.Errorf("no events for this year")
+		return nil, fmt.Errorf("%w for this year", ErrNoEvents)
 	}
 
 	return eventsForYear, nil
 }
 
-func (c *Cache) checkEventIdExistence(eventId string) (int, bool) {
+func (c *Cache) EventsCount() int {
 	c.RLock()
 	defer c.RUnlock()
+
+	return len(c.events)
+}
+
+// filterEvents returns archived events matching the predicate (if requested)
+// followed by the matching active events.
+func (c *Cache) filterEvents(match func(model.Event) bool, includeArchived bool) []model.Event {
+	result := make([]model.Event, 0)
+
+	c.RLock()
+	defer c.RUnlock()
+
+	if includeArchived {
+		for _, event := range c.archive {
+			if match(event) {
+				result = append(result, event)
+			}
+		}
+	}
+
+	for _, event := range c.events {
+		if match(event) {
+			result = append(result, event)
+		}
+	}
+
+	return result
+}
+
+// checkEventIdExistence returns the index of the active event with eventId.
+// The caller must hold the lock, so that the compactor doesn't move events
+// between the lookup and the use of the index.
+func (c *Cache) checkEventIdExistence(eventId string) (int, bool) {
 	for i, event := range c.events {
 		if event.EventId == eventId {
 			return i, true
@@ -129,6 +168,18 @@ func (c *Cache) checkEventIdExistence(eventId string) (int, bool) {
 	return 0, false
 }
 
+// checkArchivedEventIdExistence reports whether the archive has an event with eventId.
+// The caller must hold the lock.
+func (c *Cache) checkArchivedEventIdExistence(eventId string) bool {
+	for _, event := range c.archive {
+		if event.EventId == eventId {
+			return true
+		}
+	}
+
+	return false
+}
+
 func areEventsEmpty(slice []model.Event) bool {
 	return len(slice) == 0
 }
@@ -2,6 +2,9 @@ package cache
 
 import (
 	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -205,14 +208,14 @@ func TestCache_GetEventsForDay(t *testing.T) {
 
 	for _, data := range validTestData {
 		date, _ := time.Parse(model.DateLayout, data.date)
-		res, err := cache.GetEventsForDay(data.userId, date)
+		res, err := cache.GetEventsForDay(data.userId, date, false)
 		assert.Equal(t, data.expected, res)
 		assert.NoError(t, err)
 	}
 
 	for _, data := range invalidTestData {
 		date, _ := time.Parse(model.DateLayout, data.date)
-		res, err := cache.GetEventsForDay(data.userId, date)
+		res, err := cache.GetEventsForDay(data.userId, date, false)
 		assert.Nil(t, res)
 		assert.Error(t, err)
 	}
@@ -271,14 +274,14 @@ func TestCache_GetEventsForWeek(t *testing.T) {
 
 	for _, data := range validTestData {
 		date, _ := time.Parse(model.DateLayout, data.date)
-		res, err := cache.GetEventsForWeek(data.userId, date)
+		res, err := cache.GetEventsForWeek(data.userId, date, false)
 		assert.Equal(t, data.expected, res)
 		assert.NoError(t, err)
 	}
 
 	for _, data := range invalidTestData {
 		date, _ := time.Parse(model.DateLayout, data.date)
-		res, err := cache.GetEventsForWeek(data.userId, date)
+		res, err := cache.GetEventsForWeek(data.userId, date, false)
 		assert.Nil(t, res)
 		assert.Error(t, err)
 	}
@@ -337,14 +340,14 @@ func TestCache_GetEventsForMonth(t *testing.T) {
 
 	for _, data := range validTestData {
 		date, _ := time.Parse(model.DateLayout, data.date)
-		res, err := cache.GetEventsForMonth(data.userId, date)
+		res, err := cache.GetEventsForMonth(data.userId, date, false)
 		assert.Equal(t, data.expected, res)
 		assert.NoError(t, err)
 	}
 
 	for _, data := range invalidTestData {
 		date, _ := time.Parse(model.DateLayout, data.date)
-		res, err := cache.GetEventsForMonth(data.userId, date)
+		res, err := cache.GetEventsForMonth(data.userId, date, false)
 		assert.Nil(t, res)
 		assert.Error(t, err)
 	}
@@ -407,3 +410,176 @@ func TestAreEventsEmpty(t *testing.T) {
 		assert.Equal(t, data.expected, res)
 	}
 }
+
+func TestCache_compact(t *testing.T) {
+	event1, _ := model.NewEvent("1", "1", "2021-01-10", "1234")
+	event2, _ := model.NewEvent("2", "1", "2021-06-10", "1234")
+	event3, _ := model.NewEvent("3", "1", "2022-03-10", "1234")
+	now, _ := time.Parse(model.DateLayout, "2022-03-22")
+
+	cache := NewCache()
+	cache.CreateEvent(event1)
+	cache.CreateEvent(event2)
+	cache.CreateEvent(event3)
+
+	cache.compact(RetentionPolicy{Months: 6}, now)
+	assert.Equal(t, []model.Event{event3}, cache.events)
+	assert.Equal(t, []model.Event{event1, event2}, cache.archive)
+	assert.Equal(t, 2, cache.RetentionStats().Archived)
+	assert.Equal(t, 2, cache.RetentionStats().ArchiveSize)
+
+	date, _ := time.Parse(model.DateLayout, "2021-01-10")
+	res, err := cache.GetEventsForDay("1", date, false)
+	assert.Nil(t, res)
+	assert.Error(t, err)
+
+	res, err = cache.GetEventsForDay("1", date, true)
+	assert.Equal(t, []model.Event{event1}, res)
+	assert.NoError(t, err)
+
+	assert.Error(t, cache.CreateEvent(event1))
+
+	now, _ = time.Parse(model.DateLayout, "2022-05-22")
+	cache.compact(RetentionPolicy{Months: 1, Purge: true}, now)
+	assert.Equal(t, []model.Event{}, cache.events)
+	assert.Equal(t, 1, cache.RetentionStats().Purged)
+	assert.Equal(t, 1, cache.RetentionStats().LastPurged)
+	assert.Equal(t, 2, cache.RetentionStats().ArchiveSize)
+
+	cache.compact(RetentionPolicy{Months: 1, ArchiveMonths: 12}, now)
+	assert.Equal(t, []model.Event{event2}, cache.archive)
+	assert.Equal(t, 1, cache.RetentionStats().ArchivePurged)
+	assert.Equal(t, 1, cache.RetentionStats().LastArchivePurged)
+	assert.Equal(t, 1, cache.RetentionStats().ArchiveSize)
+	assert.NoError(t, cache.CreateEvent(event1))
+}
+
+func TestCache_compactConcurrently(t *testing.T) {
+	const count = 500
+	now, _ := time.Parse(model.DateLayout, "2022-03-22")
+
+	cache := NewCache()
+	for i := 0; i < count; i++ {
+		date := "2021-01-10"
+		if i%3 == 0 {
+			date = "2022-03-10"
+		}
+		event, _ := model.NewEvent(strconv.Itoa(i), "1", date, "created")
+		cache.CreateEvent(event)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				cache.compact(RetentionPolicy{Months: 6}, now)
+			}
+		}
+	}()
+
+	// Events are archived by the compactor at any moment, so update and delete
+	// either change the right event or report that it doesn't exist.
+	var workers sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		workers.Add(1)
+		go func(w int) {
+			defer workers.Done()
+			for i := count - 1; i >= 0; i-- {
+				if i%2 == w {
+					continue
+				}
+				event, _ := model.NewEvent(strconv.Itoa(i), "1", "2022-03-10", "updated")
+				if w == 0 {
+					cache.DeleteEvent(event.EventId)
+				} else {
+					cache.UpdateEvent(event)
+				}
+			}
+		}(w)
+	}
+	workers.Wait()
+	close(done)
+	wg.Wait()
+
+	// Deleted events may only stay in the archive, other events are neither lost nor duplicated.
+	active := make(map[string]int)
+	for _, event := range cache.events {
+		active[event.EventId]++
+	}
+	archived := make(map[string]int)
+	for _, event := range cache.archive {
+		archived[event.EventId]++
+	}
+	for i := 0; i < count; i++ {
+		id := strconv.Itoa(i)
+		if i%2 == 1 {
+			assert.Equal(t, 0, active[id], id)
+			assert.LessOrEqual(t, archived[id], 1, id)
+		} else {
+			assert.Equal(t, 1, active[id]+archived[id], id)
+		}
+	}
+}
+
+func TestTenants_Get(t *testing.T) {
+	event1, _ := model.NewEvent("1", "1", "2022-03-22", "1234")
+	event2, _ := model.NewEvent("2", "1", "2022-03-22", "1234")
+	date, _ := time.Parse(model.DateLayout, "2022-03-22")
+
+	tenants := NewTenants(TenantConfig{}, map[string]TenantConfig{
+		"limited": {MaxEvents: 1},
+	}, 2)
+
+	first, err := tenants.GetOrCreate("first")
+	assert.NoError(t, err)
+	assert.Same(t, first, tenants.Get("first"))
+	assert.NoError(t, first.CreateEvent(event1))
+
+	second, err := tenants.GetOrCreate("second")
+	assert.NoError(t, err)
+	assert.NoError(t, second.CreateEvent(event1))
+
+	res, err := tenants.Get("second").GetEventsForDay("1", date, false)
+	assert.Equal(t, []model.Event{event1}, res)
+	assert.NoError(t, err)
+
+	// Reading doesn't create the tenant.
+	res, err = tenants.Get("third").GetEventsForDay("1", date, false)
+	assert.Nil(t, res)
+	assert.Error(t, err)
+	assert.NotContains(t, tenants.caches, "third")
+
+	// Only tenants without explicit configuration are limited.
+	_, err = tenants.GetOrCreate("third")
+	assert.ErrorIs(t, err, ErrTenantsLimit)
+
+	limited, err := tenants.GetOrCreate("limited")
+	assert.NoError(t, err)
+	assert.NoError(t, limited.CreateEvent(event1))
+	assert.ErrorIs(t, limited.CreateEvent(event2), ErrEventsLimit)
+
+	assert.Equal(t, TenantStats{Tenant: "first", Events: 1}, tenants.Stats("first"))
+	assert.Equal(t, TenantStats{Tenant: "limited", Events: 1, MaxEvents: 1}, tenants.Stats("limited"))
+	assert.Equal(t, TenantStats{Tenant: "third", Events: 0}, tenants.Stats("third"))
+	assert.NotContains(t, tenants.caches, "third")
+}
+
+func TestValidateTenantId(t *testing.T) {
+	for _, tenantId := range []string{"a", "tenant-1", "Tenant_2.prod", strings.Repeat("a", MaxTenantIdLength)} {
+		assert.NoError(t, ValidateTenantId(tenantId), tenantId)
+	}
+
+	for _, tenantId := range []string{"", "a b", "a/b", "тенант", strings.Repeat("a", MaxTenantIdLength+1)} {
+		assert.ErrorIs(t, ValidateTenantId(tenantId), ErrInvalidTenant, tenantId)
+	}
+
+	tenants := NewTenants(TenantConfig{}, nil, 0)
+	_, err := tenants.GetOrCreate("a b")
+	assert.ErrorIs(t, err, ErrInvalidTenant)
+}
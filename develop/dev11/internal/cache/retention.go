@@ -0,0 +1,138 @@
+package cache
+
+import "time"
+
+import "github.com/hurstcain/tasks_l2/develop/dev11/internal/model"
+
+// RetentionPolicy describes how the background compactor treats old events.
+// Months is the age after which an event is compacted, zero disables compaction.
+// If Purge is set, old events are dropped instead of being moved to the archive.
+// ArchiveMonths is the age after which archived events are dropped, zero keeps them forever.
+type RetentionPolicy struct {
+	Months        int
+	Purge         bool
+	ArchiveMonths int
+	Interval      time.Duration
+}
+
+type RetentionStats struct {
+	Archived          int       `json:"archived"`
+	Purged            int       `json:"purged"`
+	ArchivePurged     int       `json:"archive_purged"`
+	ArchiveSize       int       `json:"archive_size"`
+	LastArchived      int       `json:"last_archived"`
+	LastPurged        int       `json:"last_purged"`
+	LastArchivePurged int       `json:"last_archive_purged"`
+	LastCompaction    time.Time `json:"last_compaction"`
+}
+
+// StartCompactor runs compaction with the given policy every policy.Interval
+// until StopCompactor is called.
+func (c *Cache) StartCompactor(policy RetentionPolicy) {
+	if policy.Months <= 0 || policy.Interval <= 0 {
+		return
+	}
+
+	c.Lock()
+	if c.stop != nil {
+		c.Unlock()
+		return
+	}
+	c.stop = make(chan struct{})
+	stop := c.stop
+	c.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+
+		c.compact(policy, time.Now())
+		for {
+			select {
+			case <-ticker.C:
+				c.compact(policy, time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *Cache) StopCompactor() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+func (c *Cache) RetentionStats() RetentionStats {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.stats
+}
+
+// compact removes from the active events everything older than policy.Months
+// relative to now and either archives or purges it. Then it drops archived events
+// older than policy.ArchiveMonths.
+func (c *Cache) compact(policy RetentionPolicy, now time.Time) {
+	threshold := now.AddDate(0, -policy.Months, 0)
+	compacted := 0
+
+	c.Lock()
+	defer c.Unlock()
+
+	kept := make([]model.Event, 0, len(c.events))
+
+	for _, event := range c.events {
+		if event.Date.Before(threshold) {
+			if !policy.Purge {
+				c.archive = append(c.archive, event)
+			}
+			compacted++
+			continue
+		}
+		kept = append(kept, event)
+	}
+	c.events = kept
+
+	if policy.Purge {
+		c.stats.Purged += compacted
+		c.stats.LastPurged = compacted
+		c.stats.LastArchived = 0
+	} else {
+		c.stats.Archived += compacted
+		c.stats.LastArchived = compacted
+		c.stats.LastPurged = 0
+	}
+	c.stats.LastArchivePurged = c.purgeArchive(policy.ArchiveMonths, now)
+	c.stats.ArchivePurged += c.stats.LastArchivePurged
+	c.stats.ArchiveSize = len(c.archive)
+	c.stats.LastCompaction = now
+}
+
+// purgeArchive drops archived events older than months relative to now
+// and returns their number. Zero months keeps the archive as is.
+// The caller must hold the lock.
+func (c *Cache) purgeArchive(months int, now time.Time) int {
+	if months <= 0 {
+		return 0
+	}
+
+	threshold := now.AddDate(0, -months, 0)
+	kept := make([]model.Event, 0, len(c.archive))
+
+	for _, event := range c.archive {
+		if !event.Date.Before(threshold) {
+			kept = append(kept, event)
+		}
+	}
+
+	purged := len(c.archive) - len(kept)
+	c.archive = kept
+
+	return purged
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// TenantConfig describes limits and retention policy of a single tenant.
+// MaxEvents = 0 means that the number of active events isn't limited.
+type TenantConfig struct {
+	MaxEvents int
+	Retention RetentionPolicy
+}
+
+type TenantStats struct {
+	Tenant    string         `json:"tenant"`
+	Events    int            `json:"events"`
+	MaxEvents int            `json:"max_events"`
+	Retention RetentionStats `json:"retention"`
+}
+
+// Errors returned by Tenants.GetOrCreate.
+var (
+	ErrInvalidTenant = errors.New("invalid tenant id")
+	ErrTenantsLimit  = errors.New("tenants limit is reached")
+)
+
+// MaxTenantIdLength is the maximum length of a tenant id in bytes.
+const MaxTenantIdLength = 64
+
+// Tenants keeps a separate Cache for every tenant, so events of different tenants
+// never see each other. Caches are created on the first write, tenants without explicit
+// configuration get defaultConfig. MaxTenants limits the number of tenants without explicit
+// configuration, zero means that the number isn't limited.
+type Tenants struct {
+	caches        map[string]*Cache
+	configs       map[string]TenantConfig
+	defaultConfig TenantConfig
+	maxTenants    int
+	sync.Mutex
+}
+
+func NewTenants(defaultConfig TenantConfig, configs map[string]TenantConfig, maxTenants int) *Tenants {
+	if configs == nil {
+		configs = make(map[string]TenantConfig)
+	}
+
+	return &Tenants{
+		caches:        make(map[string]*Cache),
+		configs:       configs,
+		defaultConfig: defaultConfig,
+		maxTenants:    maxTenants,
+	}
+}
+
+// ValidateTenantId checks that the tenant id is not empty, is not longer than MaxTenantIdLength
+// and consists of latin letters, digits, '-', '_' and '.'.
+func ValidateTenantId(tenantId string) error {
+	if tenantId == "" || len(tenantId) > MaxTenantIdLength {
+		return fmt.Errorf("%w: length must be from 1 to %d", ErrInvalidTenant, MaxTenantIdLength)
+	}
+
+	for _, r := range tenantId {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("%w: unexpected character %q", ErrInvalidTenant, r)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the cache of the tenant for reading. Unknown tenants get an empty cache
+// which isn't stored, so reads never create tenants or start compactors.
+func (t *Tenants) Get(tenantId string) *Cache {
+	t.Lock()
+	defer t.Unlock()
+
+	if c, ok := t.caches[tenantId]; ok {
+		return c
+	}
+
+	return t.newCache(t.config(tenantId))
+}
+
+// GetOrCreate returns the cache of the tenant for writing, creating it and starting its compactor if needed.
+// Returns ErrInvalidTenant if the tenant id is invalid and ErrTenantsLimit if a new tenant
+// without explicit configuration exceeds the limit.
+func (t *Tenants) GetOrCreate(tenantId string) (*Cache, error) {
+	if err := ValidateTenantId(tenantId); err != nil {
+		return nil, err
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if c, ok := t.caches[tenantId]; ok {
+		return c, nil
+	}
+
+	config, configured := t.configs[tenantId]
+	if !configured {
+		if t.maxTenants > 0 && t.unconfiguredCount() >= t.maxTenants {
+			return nil, ErrTenantsLimit
+		}
+		config = t.defaultConfig
+	}
+
+	c := t.newCache(config)
+	c.StartCompactor(config.Retention)
+	t.caches[tenantId] = c
+
+	return c, nil
+}
+
+func (t *Tenants) StopCompactors() {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, c := range t.caches {
+		c.StopCompactor()
+	}
+}
+
+// Stats returns statistics of the tenant. Unknown tenants have no events.
+func (t *Tenants) Stats(tenantId string) TenantStats {
+	c := t.Get(tenantId)
+
+	return TenantStats{
+		Tenant:    tenantId,
+		Events:    c.EventsCount(),
+		MaxEvents: c.maxEvents,
+		Retention: c.RetentionStats(),
+	}
+}
+
+func (t *Tenants) newCache(config TenantConfig) *Cache {
+	c := NewCache()
+	c.maxEvents = config.MaxEvents
+
+	return c
+}
+
+// unconfiguredCount returns the number of created tenants without explicit configuration.
+// The caller must hold the lock.
+func (t *Tenants) unconfiguredCount() int {
+	n := 0
+	for tenantId := range t.caches {
+		if _, ok := t.configs[tenantId]; !ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (t *Tenants) config(tenantId string) TenantConfig {
+	if config, ok := t.configs[tenantId]; ok {
+		return config
+	}
+
+	return t.defaultConfig
+}
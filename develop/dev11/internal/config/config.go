@@ -1,6 +1,51 @@
 package config
 
+import "time"
+
+const (
+	Port     = "8000"
+	GrpcPort = "9000"
+	Ip       = "127.0.0.1"
+)
+
+// Retention policy of the events cache. Events older than RetentionMonths
+// are moved to the archive (or dropped if RetentionPurge is set)
+// every CompactionInterval. RetentionMonths = 0 disables compaction, so
+// retention is opt-in and existing events stay active after an upgrade.
+// Archived events older than ArchiveRetentionMonths are dropped,
+// ArchiveRetentionMonths = 0 keeps the archive forever.
+const (
+	RetentionMonths        = 0
+	RetentionPurge         = false
+	ArchiveRetentionMonths = 0
+	CompactionInterval     = time.Hour
+)
+
+// Tenant is passed in the TenantHeader header (x-tenant-id metadata for gRPC).
+// Requests without it belong to DefaultTenant. Tenants are created on the first write,
+// MaxTenants limits the number of tenants which are not listed in Tenants.
 const (
-	Port = "8000"
-	Ip   = "127.0.0.1"
+	TenantHeader  = "X-Tenant-Id"
+	DefaultTenant = "default"
+	MaxTenants    = 1000
+)
+
+// Tenant describes settings of a single tenant. MaxEvents = 0 disables the limit.
+type Tenant struct {
+	MaxEvents              int
+	RetentionMonths        int
+	RetentionPurge         bool
+	ArchiveRetentionMonths int
+}
+
+// Tenants contains settings of tenants which differ from the default ones.
+// Tenants that are not listed here use DefaultTenantSettings.
+var (
+	DefaultTenantSettings = Tenant{
+		MaxEvents:              0,
+		RetentionMonths:        RetentionMonths,
+		RetentionPurge:         RetentionPurge,
+		ArchiveRetentionMonths: ArchiveRetentionMonths,
+	}
+	Tenants = map[string]Tenant{}
 )
@@ -0,0 +1,37 @@
+package grpcservice
+
+import "time"
+
+import (
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/model"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/pb"
+)
+
+func eventToPb(event model.Event) *pb.Event {
+	return &pb.Event{
+		EventId:      event.EventId,
+		UserId:       event.UserId,
+		Date:         event.DateString,
+		EventContent: event.EventContent,
+	}
+}
+
+func eventsToPb(events []model.Event) *pb.EventsResponse {
+	result := make([]*pb.Event, 0, len(events))
+	for _, event := range events {
+		result = append(result, eventToPb(event))
+	}
+
+	return &pb.EventsResponse{Result: result}
+}
+
+func parseEventsRequest(req *pb.EventsRequest) (string, time.Time, bool, error) {
+	userId := req.GetUserId()
+
+	if err := model.CheckUserId(userId); err != nil {
+		return "", time.Time{}, false, err
+	}
+
+	t, err := model.CheckDate(req.GetDate())
+	return userId, t, req.GetIncludeArchived(), err
+}
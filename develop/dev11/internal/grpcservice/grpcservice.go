@@ -0,0 +1,235 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+import (
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/cache"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/config"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/model"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/pb"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/service/logger"
+)
+
+type Service struct {
+	pb.UnimplementedCalendarServer
+	addr    string
+	server  *grpc.Server
+	tenants *cache.Tenants
+	logger  *logger.Logger
+}
+
+func NewService(tenants *cache.Tenants) *Service {
+	service := &Service{
+		addr:    config.Ip + ":" + config.GrpcPort,
+		tenants: tenants,
+		logger:  logger.NewLogger(),
+	}
+
+	service.server = grpc.NewServer(grpc.UnaryInterceptor(service.logger.LogUnaryCall))
+	pb.RegisterCalendarServer(service.server, service)
+
+	return service
+}
+
+func (s *Service) Run() {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.logger.Printf("Error when running grpc server: %s\n", err.Error())
+		return
+	}
+
+	err = s.server.Serve(listener)
+	if err == grpc.ErrServerStopped {
+		return
+	}
+	if err != nil {
+		s.logger.Printf("Error when running grpc server: %s\n", err.Error())
+	}
+}
+
+func (s *Service) Stop() {
+	s.logger.Println("\nClosing grpc server...")
+	s.server.GracefulStop()
+}
+
+func (s *Service) CreateEvent(ctx context.Context, req *pb.Event) (*pb.EventResponse, error) {
+	event, err := model.NewEvent(req.GetEventId(), req.GetUserId(), req.GetDate(), req.GetEventContent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c, err := s.writableCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.CreateEvent(event); err != nil {
+		return nil, cacheError(err)
+	}
+
+	return &pb.EventResponse{Result: eventToPb(event)}, nil
+}
+
+func (s *Service) UpdateEvent(ctx context.Context, req *pb.Event) (*pb.EventResponse, error) {
+	event, err := model.NewEvent(req.GetEventId(), req.GetUserId(), req.GetDate(), req.GetEventContent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c, err := s.writableCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.UpdateEvent(event); err != nil {
+		return nil, cacheError(err)
+	}
+
+	return &pb.EventResponse{Result: eventToPb(event)}, nil
+}
+
+func (s *Service) DeleteEvent(ctx context.Context, req *pb.DeleteEventRequest) (*pb.DeleteEventResponse, error) {
+	if err := model.CheckEventId(req.GetEventId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c, err := s.writableCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.DeleteEvent(req.GetEventId()); err != nil {
+		return nil, cacheError(err)
+	}
+
+	return &pb.DeleteEventResponse{Result: "Event has been deleted"}, nil
+}
+
+func (s *Service) GetEventsForDay(ctx context.Context, req *pb.EventsRequest) (*pb.EventsResponse, error) {
+	userId, date, includeArchived, err := parseEventsRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c, err := s.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := c.GetEventsForDay(userId, date, includeArchived)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+
+	return eventsToPb(events), nil
+}
+
+func (s *Service) GetEventsForWeek(ctx context.Context, req *pb.EventsRequest) (*pb.EventsResponse, error) {
+	userId, date, includeArchived, err := parseEventsRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c, err := s.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := c.GetEventsForWeek(userId, date, includeArchived)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+
+	return eventsToPb(events), nil
+}
+
+func (s *Service) GetEventsForMonth(ctx context.Context, req *pb.EventsRequest) (*pb.EventsResponse, error) {
+	userId, date, includeArchived, err := parseEventsRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c, err := s.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := c.GetEventsForMonth(userId, date, includeArchived)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+
+	return eventsToPb(events), nil
+}
+
+// cache returns the events cache of the tenant passed in the call metadata for reading.
+// It doesn't create the tenant.
+func (s *Service) cache(ctx context.Context) (*cache.Cache, error) {
+	tenant, err := callTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tenants.Get(tenant), nil
+}
+
+// writableCache returns the events cache of the tenant passed in the call metadata,
+// creating the tenant if needed.
+func (s *Service) writableCache(ctx context.Context) (*cache.Cache, error) {
+	tenant, err := callTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := s.tenants.GetOrCreate(tenant)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+
+	return c, nil
+}
+
+// callTenant returns the tenant passed in the call metadata.
+func callTenant(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return config.DefaultTenant, nil
+	}
+
+	values := md.Get(config.TenantHeader)
+	if len(values) == 0 || values[0] == "" {
+		return config.DefaultTenant, nil
+	}
+
+	if err := cache.ValidateTenantId(values[0]); err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return values[0], nil
+}
+
+// cacheError converts an error returned by the cache to a gRPC status error.
+func cacheError(err error) error {
+	code := codes.Unavailable
+	switch {
+	case errors.Is(err, cache.ErrEventNotFound), errors.Is(err, cache.ErrNoEvents):
+		code = codes.NotFound
+	case errors.Is(err, cache.ErrEventExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, cache.ErrEventsLimit), errors.Is(err, cache.ErrTenantsLimit):
+		code = codes.ResourceExhausted
+	case errors.Is(err, cache.ErrInvalidTenant):
+		code = codes.InvalidArgument
+	}
+
+	return status.Error(code, err.Error())
+}
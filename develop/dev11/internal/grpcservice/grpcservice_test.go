@@ -0,0 +1,116 @@
+package grpcservice
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+import (
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/cache"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/pb"
+)
+
+func TestService_CreateEvent(t *testing.T) {
+	s := &Service{tenants: cache.NewTenants(cache.TenantConfig{}, nil, 0)}
+	event := &pb.Event{EventId: "1", UserId: "1", Date: "2022-03-22", EventContent: "1234"}
+
+	resp, err := s.CreateEvent(context.Background(), event)
+	assert.NoError(t, err)
+	assert.Equal(t, event.GetEventId(), resp.GetResult().GetEventId())
+	assert.Equal(t, event.GetDate(), resp.GetResult().GetDate())
+
+	_, err = s.CreateEvent(context.Background(), event)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = s.CreateEvent(context.Background(), &pb.Event{EventId: "2", UserId: "1", Date: "22-03-2022"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestService_GetEvents(t *testing.T) {
+	s := &Service{tenants: cache.NewTenants(cache.TenantConfig{}, nil, 0)}
+	s.CreateEvent(context.Background(), &pb.Event{EventId: "1", UserId: "1", Date: "2022-03-22"})
+	s.CreateEvent(context.Background(), &pb.Event{EventId: "2", UserId: "1", Date: "2022-03-23"})
+	s.CreateEvent(context.Background(), &pb.Event{EventId: "3", UserId: "2", Date: "2022-03-22"})
+
+	resp, err := s.GetEventsForDay(context.Background(), &pb.EventsRequest{UserId: "1", Date: "2022-03-22"})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetResult(), 1)
+
+	resp, err = s.GetEventsForWeek(context.Background(), &pb.EventsRequest{UserId: "1", Date: "2022-03-21"})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetResult(), 2)
+
+	_, err = s.GetEventsForMonth(context.Background(), &pb.EventsRequest{UserId: "3", Date: "2022-03-21"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = s.GetEventsForMonth(context.Background(), &pb.EventsRequest{Date: "2022-03-21"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestService_DeleteEvent(t *testing.T) {
+	s := &Service{tenants: cache.NewTenants(cache.TenantConfig{}, nil, 0)}
+	s.CreateEvent(context.Background(), &pb.Event{EventId: "1", UserId: "1", Date: "2022-03-22"})
+
+	_, err := s.DeleteEvent(context.Background(), &pb.DeleteEventRequest{EventId: "1"})
+	assert.NoError(t, err)
+
+	_, err = s.DeleteEvent(context.Background(), &pb.DeleteEventRequest{EventId: "1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = s.UpdateEvent(context.Background(), &pb.Event{EventId: "1", UserId: "1", Date: "2022-03-22"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = s.DeleteEvent(context.Background(), &pb.DeleteEventRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestService_cache(t *testing.T) {
+	s := &Service{tenants: cache.NewTenants(cache.TenantConfig{}, nil, 0)}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "first"))
+	event := &pb.Event{EventId: "1", UserId: "1", Date: "2022-03-22"}
+
+	_, err := s.CreateEvent(ctx, event)
+	assert.NoError(t, err)
+
+	_, err = s.CreateEvent(context.Background(), event)
+	assert.NoError(t, err)
+
+	_, err = s.CreateEvent(ctx, event)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	c, err := s.cache(ctx)
+	assert.NoError(t, err)
+	assert.Same(t, s.tenants.Get("first"), c)
+
+	c, err = s.cache(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, s.tenants.Get("default"), c)
+
+	// Reading doesn't create the tenant.
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "second"))
+	_, err = s.GetEventsForDay(ctx, &pb.EventsRequest{UserId: "1", Date: "2022-03-22"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, 0, s.tenants.Stats("second").Events)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "a b"))
+	_, err = s.CreateEvent(ctx, event)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestService_limit(t *testing.T) {
+	s := &Service{tenants: cache.NewTenants(cache.TenantConfig{MaxEvents: 1}, nil, 1)}
+
+	_, err := s.CreateEvent(context.Background(), &pb.Event{EventId: "1", UserId: "1", Date: "2022-03-22"})
+	assert.NoError(t, err)
+
+	_, err = s.CreateEvent(context.Background(), &pb.Event{EventId: "2", UserId: "1", Date: "2022-03-22"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "second"))
+	_, err = s.CreateEvent(ctx, &pb.Event{EventId: "1", UserId: "1", Date: "2022-03-22"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
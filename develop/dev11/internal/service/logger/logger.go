@@ -1,10 +1,13 @@
 package logger
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 type Logger struct {
@@ -30,3 +33,16 @@ func (l *Logger) LogRequest(handler http.Handler) http.HandlerFunc {
 		handler.ServeHTTP(w, r)
 	}
 }
+
+func (l *Logger) LogUnaryCall(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	l.Println("\n[" + time.Now().Format("2006-01-02 15:04:05") + "]")
+	l.Printf("-----gRPC call-----\nmethod: %s\n", info.FullMethod)
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		l.Printf("Request is not fulfilled. Error: %s", err.Error())
+	}
+
+	return resp, err
+}
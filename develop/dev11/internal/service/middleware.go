@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+const ContentTypeForm = "application/x-www-form-urlencoded"
+
+// HandlerFunc is an http handler which doesn't write error responses itself
+// but returns the error to be rendered by Service.handle.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Middleware wraps a HandlerFunc with additional request processing.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// StatusError is an error which has to be sent to the client with the given http status.
+type StatusError struct {
+	Status int
+	Err    error
+	// ContentType of the error response negotiated by a GetResponse endpoint, JSON if empty.
+	ContentType string
+	// StatusOnly errors are sent without ErrorResponse body.
+	StatusOnly bool
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+func newStatusError(status int, err error) *StatusError {
+	return &StatusError{
+		Status: status,
+		Err:    err,
+	}
+}
+
+// newRequestError makes an error of an invalid request. As before the middleware chain,
+// such requests are answered with the status only.
+func newRequestError(status int, err error) *StatusError {
+	return &StatusError{
+		Status:     status,
+		Err:        err,
+		StatusOnly: true,
+	}
+}
+
+func sendStatusError(w http.ResponseWriter, e *StatusError) error {
+	if e.StatusOnly {
+		w.WriteHeader(e.Status)
+		return nil
+	}
+	if e.ContentType == "" {
+		return SendErrorResponse(w, e.Status, e.Err)
+	}
+
+	return SendGetErrorResponse(w, e.ContentType, e.Status, e.Err)
+}
+
+// Chain wraps h with middlewares. The first middleware is the outermost one.
+func Chain(h HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+
+	return h
+}
+
+func AllowMethod(method string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if r.Method != method {
+				return newRequestError(http.StatusInternalServerError, fmt.Errorf("wrong method %s, expected %s", r.Method, method))
+			}
+
+			return next(w, r)
+		}
+	}
+}
+
+func RequireContentType(contentType string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != contentType {
+				return newRequestError(http.StatusInternalServerError,
+					fmt.Errorf("wrong content-type %q, expected %s", r.Header.Get("Content-Type"), contentType))
+			}
+
+			return next(w, r)
+		}
+	}
+}
+
+func ParseForm(next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := r.ParseForm(); err != nil {
+			return newRequestError(http.StatusBadRequest, fmt.Errorf("can't parse data from body: %w", err))
+		}
+
+		return next(w, r)
+	}
+}
+
+// Decode converts form values of the request into T and passes it to handle.
+// Body values are used for POST requests, query values for the other methods.
+// Requires ParseForm earlier in the chain.
+func Decode[T any](decode func(url.Values) (T, error), handle func(http.ResponseWriter, *http.Request, T) error) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		values := r.Form
+		if r.Method == http.MethodPost {
+			values = r.PostForm
+		}
+
+		req, err := decode(values)
+		if err != nil {
+			return newRequestError(http.StatusBadRequest, fmt.Errorf("invalid data: %w", err))
+		}
+
+		return handle(w, r, req)
+	}
+}
+
+// Post is the chain of POST endpoints receiving url-encoded forms.
+func Post(h HandlerFunc) HandlerFunc {
+	return Chain(h, AllowMethod(http.MethodPost), RequireContentType(ContentTypeForm), ParseForm)
+}
+
+// Get is the chain of GET endpoints receiving parameters in the query string.
+func Get(h HandlerFunc) HandlerFunc {
+	return Chain(h, AllowMethod(http.MethodGet), ParseForm)
+}
+
+// handle converts h into http.HandlerFunc rendering returned errors.
+// StatusError is sent to the client as ErrorResponse, other errors occur
+// when the response is already being written and are only logged.
+func (s *Service) handle(h HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := h(w, r)
+		if err == nil {
+			return
+		}
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			s.logger.Printf("Error: %s", err.Error())
+			return
+		}
+
+		s.logger.Printf("Request is not fulfilled. Error: %s", err.Error())
+		if responseErr := sendStatusError(w, statusErr); responseErr != nil {
+			s.logger.Printf("Error: %s", responseErr.Error())
+		}
+	}
+}
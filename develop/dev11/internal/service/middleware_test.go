@@ -0,0 +1,162 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/cache"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/service/logger"
+)
+
+func newTestService() *Service {
+	l := new(logger.Logger)
+	l.SetOutput(io.Discard)
+
+	return &Service{
+		tenants: cache.NewTenants(cache.TenantConfig{}, nil, 0),
+		logger:  l,
+	}
+}
+
+func TestService_handle(t *testing.T) {
+	s := newTestService()
+	create := s.handle(Post(Decode(parseBodyToEvent, s.CreateEvent)))
+	getDay := s.handle(Get(Decode(parseEventsQuery, s.GetEventsForDay)))
+
+	testData := []struct {
+		handler     http.HandlerFunc
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		response    string
+	}{
+		{
+			handler:     create,
+			method:      http.MethodPost,
+			target:      "/create_event",
+			contentType: "application/x-www-form-urlencoded; charset=utf-8",
+			body:        "event_id=1&user_id=1&date=2022-03-22&event_content=abc",
+			status:      http.StatusOK,
+			response:    `{"result":{"event_id":"1","user_id":"1","date":"2022-03-22","event_content":"abc"}}`,
+		},
+		{
+			handler:     create,
+			method:      http.MethodPost,
+			target:      "/create_event",
+			contentType: ContentTypeForm,
+			body:        "event_id=1&user_id=1&date=2022-03-22&event_content=abc",
+			status:      http.StatusServiceUnavailable,
+			response:    `{"error":"event with this id already exists"}`,
+		},
+		{
+			handler: create,
+			method:  http.MethodGet,
+			target:  "/create_event",
+			status:  http.StatusInternalServerError,
+		},
+		{
+			handler:     create,
+			method:      http.MethodPost,
+			target:      "/create_event",
+			contentType: "application/json",
+			body:        "{}",
+			status:      http.StatusInternalServerError,
+		},
+		{
+			handler:     create,
+			method:      http.MethodPost,
+			target:      "/create_event?event_id=2&user_id=1&date=2022-03-22",
+			contentType: ContentTypeForm,
+			status:      http.StatusBadRequest,
+		},
+		{
+			handler:  getDay,
+			method:   http.MethodGet,
+			target:   "/events_for_day?user_id=1&date=2022-03-22",
+			status:   http.StatusOK,
+			response: `{"result":[{"event_id":"1","user_id":"1","date":"2022-03-22","event_content":"abc"}]}`,
+		},
+		{
+			handler: getDay,
+			method:  http.MethodGet,
+			target:  "/events_for_day?user_id=1&date=22.03.2022",
+			status:  http.StatusBadRequest,
+		},
+		{
+			handler: getDay,
+			method:  http.MethodGet,
+			target:  "/events_for_day?user_id=1&date=2022-03-22&include_archived=maybe",
+			status:  http.StatusBadRequest,
+		},
+	}
+
+	for _, data := range testData {
+		r := httptest.NewRequest(data.method, data.target, strings.NewReader(data.body))
+		if data.contentType != "" {
+			r.Header.Set("Content-Type", data.contentType)
+		}
+		w := httptest.NewRecorder()
+
+		data.handler(w, r)
+		assert.Equal(t, data.status, w.Code, data.target)
+		// Invalid requests are answered with the status only.
+		if data.response == "" {
+			assert.Empty(t, w.Body.String(), data.target)
+		} else {
+			assert.JSONEq(t, data.response, w.Body.String(), data.target)
+		}
+	}
+}
+
+func TestService_tenants(t *testing.T) {
+	s := newTestService()
+	create := s.handle(Post(Decode(parseBodyToEvent, s.CreateEvent)))
+	getDay := s.handle(Get(Decode(parseEventsQuery, s.GetEventsForDay)))
+	stats := s.handle(Get(s.GetTenantStats))
+
+	request := func(handler http.HandlerFunc, method, target, tenant, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", ContentTypeForm)
+		if tenant != "" {
+			r.Header.Set("X-Tenant-Id", tenant)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	// Reading doesn't create the tenant, so the only allowed tenant can still be created.
+	s.tenants = cache.NewTenants(cache.TenantConfig{}, nil, 1)
+	w := request(getDay, http.MethodGet, "/events_for_day?user_id=1&date=2022-03-22", "second", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = request(create, http.MethodPost, "/create_event", "first", "event_id=1&user_id=1&date=2022-03-22")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request(create, http.MethodPost, "/create_event", "second", "event_id=1&user_id=1&date=2022-03-22")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error":"tenants limit is reached"}`, w.Body.String())
+
+	w = request(getDay, http.MethodGet, "/events_for_day?user_id=1&date=2022-03-22", "a b", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Only the stats of the caller's tenant are returned.
+	w = request(stats, http.MethodGet, "/tenant_stats", "first", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"result":[{"tenant":"first","events":1,"max_events":0,"retention":{"archived":0,"purged":0,
+		"archive_purged":0,"archive_size":0,"last_archived":0,"last_purged":0,"last_archive_purged":0,
+		"last_compaction":"0001-01-01T00:00:00Z"}}]}`, w.Body.String())
+
+	w = request(stats, http.MethodGet, "/tenant_stats", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"tenant":"default","events":0`)
+	assert.NotContains(t, w.Body.String(), "first")
+}
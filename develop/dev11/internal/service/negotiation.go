@@ -0,0 +1,311 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+import "github.com/hurstcain/tasks_l2/develop/dev11/internal/model"
+
+const (
+	ContentTypeJSON      = "application/json"
+	ContentTypeCSV       = "text/csv"
+	ContentTypeICalendar = "text/calendar"
+	ContentTypeHTML      = "text/html"
+)
+
+type getResponseEncoder func(w io.Writer, response GetResponse) error
+
+// getResponseTypes lists content types of GetResponse in order of server preference.
+// The first one is used when the client doesn't send Accept.
+var getResponseTypes = []string{ContentTypeJSON, ContentTypeCSV, ContentTypeICalendar, ContentTypeHTML}
+
+var getResponseEncoders = map[string]getResponseEncoder{
+	ContentTypeJSON:      encodeJSON,
+	ContentTypeCSV:       encodeCSV,
+	ContentTypeICalendar: encodeICalendar,
+	ContentTypeHTML:      encodeHTML,
+}
+
+type mediaRange struct {
+	mediaType string
+	subType   string
+	q         float64
+}
+
+// negotiateContentType chooses the content type of GetResponse according to the Accept header.
+// Returns false if none of the supported types is acceptable.
+func negotiateContentType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return getResponseTypes[0], true
+	}
+
+	ranges := parseAccept(accept)
+	best := ""
+	bestQ := 0.0
+
+	for _, contentType := range getResponseTypes {
+		q := acceptQuality(ranges, contentType)
+		if q > bestQ {
+			best = contentType
+			bestQ = q
+		}
+	}
+
+	return best, best != ""
+}
+
+func parseAccept(accept string) []mediaRange {
+	ranges := make([]mediaRange, 0)
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType, subType, ok := strings.Cut(strings.TrimSpace(params[0]), "/")
+		if !ok {
+			continue
+		}
+
+		r := mediaRange{
+			mediaType: strings.ToLower(mediaType),
+			subType:   strings.ToLower(subType),
+			q:         1,
+		}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(key) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(value, 64)
+			if err == nil && q >= 0 && q <= 1 {
+				r.q = q
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	// The most specific media range takes precedence: type/subtype, then type/*, then */*.
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	return ranges
+}
+
+func acceptQuality(ranges []mediaRange, contentType string) float64 {
+	mediaType, subType, _ := strings.Cut(contentType, "/")
+
+	for _, r := range ranges {
+		if (r.mediaType == "*" || r.mediaType == mediaType) && (r.subType == "*" || r.subType == subType) {
+			return r.q
+		}
+	}
+
+	return 0
+}
+
+func (r mediaRange) specificity() int {
+	switch {
+	case r.mediaType == "*":
+		return 0
+	case r.subType == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func encodeJSON(w io.Writer, response GetResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func encodeCSV(w io.Writer, response GetResponse) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{ParamEventId, ParamUserId, ParamDate, ParamEventContent}); err != nil {
+		return err
+	}
+	for _, event := range response.Result {
+		record := []string{event.EventId, event.UserId, event.DateString, event.EventContent}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func encodeICalendar(w io.Writer, response GetResponse) error {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	lines := make([]string, 0, 6*len(response.Result))
+	for _, event := range response.Result {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+escapeICalendar(event.EventId+"-"+event.UserId),
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+event.Date.Format("20060102"),
+			"SUMMARY:"+escapeICalendar(event.EventContent),
+			"END:VEVENT",
+		)
+	}
+
+	return writeICalendar(w, lines)
+}
+
+// writeICalendar writes a VCALENDAR object with the given content lines.
+// Lines are folded as required by RFC 5545 section 3.1.
+func writeICalendar(w io.Writer, lines []string) error {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//tasks_l2//dev11 calendar//EN\r\n")
+	for _, line := range lines {
+		b.WriteString(foldICalendarLine(line))
+		b.WriteString("\r\n")
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// icalendarLineLength is the maximum length of a content line in octets, excluding the line break.
+const icalendarLineLength = 75
+
+// foldICalendarLine splits a content line longer than icalendarLineLength octets into several lines.
+// Every continuation line starts with a space, and UTF-8 sequences are never split.
+func foldICalendarLine(line string) string {
+	var b strings.Builder
+
+	limit := icalendarLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards its length.
+		limit = icalendarLineLength - 1
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
+
+func escapeICalendar(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+var agendaTemplate = template.Must(template.New("agenda").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Agenda</title>
+</head>
+<body>
+<h1>Agenda</h1>
+{{range .}}<h2>{{.Date}}</h2>
+<table border="1">
+<tr><th>event_id</th><th>user_id</th><th>event_content</th></tr>
+{{range .Events}}<tr><td>{{.EventId}}</td><td>{{.UserId}}</td><td>{{.EventContent}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+type agendaDay struct {
+	Date   string
+	Events []model.Event
+}
+
+func encodeHTML(w io.Writer, response GetResponse) error {
+	events := make([]model.Event, len(response.Result))
+	copy(events, response.Result)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Date.Before(events[j].Date)
+	})
+
+	days := make([]agendaDay, 0)
+	for _, event := range events {
+		if len(days) == 0 || days[len(days)-1].Date != event.DateString {
+			days = append(days, agendaDay{Date: event.DateString})
+		}
+		days[len(days)-1].Events = append(days[len(days)-1].Events, event)
+	}
+
+	return agendaTemplate.Execute(w, days)
+}
+
+// getErrorEncoders render an error of a GetResponse endpoint in the negotiated content type,
+// so clients get errors in the format they asked for.
+var getErrorEncoders = map[string]func(w io.Writer, err error) error{
+	ContentTypeJSON:      encodeJSONError,
+	ContentTypeCSV:       encodeCSVError,
+	ContentTypeICalendar: encodeICalendarError,
+	ContentTypeHTML:      encodeHTMLError,
+}
+
+func encodeJSONError(w io.Writer, err error) error {
+	data, marshalErr := json.Marshal(ErrorResponse{Error: err.Error()})
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	_, writeErr := w.Write(data)
+	return writeErr
+}
+
+func encodeCSVError(w io.Writer, err error) error {
+	writer := csv.NewWriter(w)
+	writer.WriteAll([][]string{{"error"}, {err.Error()}})
+
+	return writer.Error()
+}
+
+// encodeICalendarError writes an empty calendar with the error in the X-ERROR property.
+func encodeICalendarError(w io.Writer, err error) error {
+	return writeICalendar(w, []string{"X-ERROR:" + escapeICalendar(err.Error())})
+}
+
+var errorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Error</title>
+</head>
+<body>
+<h1>Error</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+func encodeHTMLError(w io.Writer, err error) error {
+	return errorTemplate.Execute(w, err.Error())
+}
+
+func unacceptableError(accept string) error {
+	return fmt.Errorf("none of the content types %s is acceptable: %s", strings.Join(getResponseTypes, ", "), accept)
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+import "github.com/hurstcain/tasks_l2/develop/dev11/internal/model"
+
+func TestNegotiateContentType(t *testing.T) {
+	validTestData := []struct {
+		accept   string
+		expected string
+	}{
+		{accept: "", expected: ContentTypeJSON},
+		{accept: "*/*", expected: ContentTypeJSON},
+		{accept: "text/csv", expected: ContentTypeCSV},
+		{accept: "text/*", expected: ContentTypeCSV},
+		{accept: "text/*;q=0.5, text/html", expected: ContentTypeHTML},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: ContentTypeHTML},
+		{accept: "application/json;q=0.1, text/calendar", expected: ContentTypeICalendar},
+		{accept: "*/*;q=0.5, application/json;q=0", expected: ContentTypeCSV},
+	}
+
+	invalidTestData := []string{
+		"application/xml",
+		"text/*;q=0, application/json;q=0",
+	}
+
+	for _, data := range validTestData {
+		res, ok := negotiateContentType(data.accept)
+		assert.Equal(t, data.expected, res, data.accept)
+		assert.True(t, ok)
+	}
+
+	for _, accept := range invalidTestData {
+		_, ok := negotiateContentType(accept)
+		assert.False(t, ok, accept)
+	}
+}
+
+func TestSendGetResponse(t *testing.T) {
+	event1, _ := model.NewEvent("1", "1", "2022-03-23", "Meeting, room 2")
+	event2, _ := model.NewEvent("2", "1", "2022-03-22", "<b>Lunch</b>")
+	events := []model.Event{event1, event2}
+
+	testData := []struct {
+		accept      string
+		contentType string
+		contains    []string
+	}{
+		{
+			accept:      "application/json",
+			contentType: ContentTypeJSON,
+			contains:    []string{`{"result":[{"event_id":"1","user_id":"1","date":"2022-03-23","event_content":"Meeting, room 2"}`},
+		},
+		{
+			accept:      "text/csv",
+			contentType: ContentTypeCSV,
+			contains:    []string{"event_id,user_id,date,event_content\n", `1,1,2022-03-23,"Meeting, room 2"`},
+		},
+		{
+			accept:      "text/calendar",
+			contentType: ContentTypeICalendar,
+			contains:    []string{"BEGIN:VCALENDAR\r\n", "DTSTART;VALUE=DATE:20220323\r\n", `SUMMARY:Meeting\, room 2`},
+		},
+		{
+			accept:      "text/html",
+			contentType: ContentTypeHTML,
+			contains:    []string{"<h2>2022-03-22</h2>", "&lt;b&gt;Lunch&lt;/b&gt;"},
+		},
+	}
+
+	for _, data := range testData {
+		r := httptest.NewRequest(http.MethodGet, "/events_for_week", nil)
+		r.Header.Set("Accept", data.accept)
+		w := httptest.NewRecorder()
+
+		err := SendGetResponse(w, r, events)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), data.contentType))
+		for _, s := range data.contains {
+			assert.Contains(t, w.Body.String(), s)
+		}
+	}
+
+	var html bytes.Buffer
+	encodeHTML(&html, GetResponse{Result: events})
+	assert.Less(t, strings.Index(html.String(), "2022-03-22"), strings.Index(html.String(), "2022-03-23"))
+
+	r := httptest.NewRequest(http.MethodGet, "/events_for_week", nil)
+	r.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+	err := SendGetResponse(w, r, events)
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotAcceptable, statusErr.Status)
+}
+
+func TestEncodeJSON(t *testing.T) {
+	event, _ := model.NewEvent("1", "1", "2022-03-23", "abc")
+
+	var b bytes.Buffer
+	err := encodeJSON(&b, GetResponse{Result: []model.Event{event}})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"result":[{"event_id":"1","user_id":"1","date":"2022-03-23","event_content":"abc"}]}`, b.String())
+}
+
+func TestFoldICalendarLine(t *testing.T) {
+	testData := []struct {
+		line     string
+		expected string
+	}{
+		{line: "SUMMARY:abc", expected: "SUMMARY:abc"},
+		{line: strings.Repeat("a", 75), expected: strings.Repeat("a", 75)},
+		{
+			line:     strings.Repeat("a", 150),
+			expected: strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n a",
+		},
+		// Two-byte runes aren't split between lines.
+		{
+			line:     "SUMMARY:" + strings.Repeat("я", 40),
+			expected: "SUMMARY:" + strings.Repeat("я", 33) + "\r\n " + strings.Repeat("я", 7),
+		},
+	}
+
+	for _, data := range testData {
+		assert.Equal(t, data.expected, foldICalendarLine(data.line))
+	}
+}
+
+func TestEncodeICalendar_longDescription(t *testing.T) {
+	content := strings.Repeat("Very long meeting description, ", 10) + "встреча в переговорной"
+	event, _ := model.NewEvent("1", "1", "2022-03-23", content)
+
+	var b bytes.Buffer
+	err := encodeICalendar(&b, GetResponse{Result: []model.Event{event}})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	var summary strings.Builder
+	for i, line := range lines {
+		assert.LessOrEqual(t, len(line), 75, line)
+		assert.True(t, utf8.ValidString(line), line)
+		if strings.HasPrefix(line, "SUMMARY:") {
+			summary.WriteString(line)
+			for _, next := range lines[i+1:] {
+				if !strings.HasPrefix(next, " ") {
+					break
+				}
+				summary.WriteString(next[1:])
+			}
+		}
+	}
+	assert.Equal(t, "SUMMARY:"+escapeICalendar(content), summary.String())
+}
+
+func TestService_emptyResult(t *testing.T) {
+	s := newTestService()
+	getDay := s.handle(Get(Decode(parseEventsQuery, s.GetEventsForDay)))
+
+	testData := []struct {
+		accept      string
+		contentType string
+		response    string
+	}{
+		{
+			accept:      "application/json",
+			contentType: ContentTypeJSON,
+			response:    `{"error":"no events for this day"}`,
+		},
+		{
+			accept:      "text/csv",
+			contentType: ContentTypeCSV,
+			response:    "error\nno events for this day\n",
+		},
+		{
+			accept:      "text/calendar",
+			contentType: ContentTypeICalendar,
+			response:    "X-ERROR:no events for this day\r\nEND:VCALENDAR\r\n",
+		},
+		{
+			accept:      "text/html",
+			contentType: ContentTypeHTML,
+			response:    "<p>no events for this day</p>",
+		},
+		// The error of unacceptable content type is sent as JSON.
+		{
+			accept:      "application/xml",
+			contentType: "text/plain",
+			response:    `{"error":"no events for this day"}`,
+		},
+	}
+
+	for _, data := range testData {
+		r := httptest.NewRequest(http.MethodGet, "/events_for_day?user_id=1&date=2022-03-22", nil)
+		r.Header.Set("Accept", data.accept)
+		w := httptest.NewRecorder()
+
+		getDay(w, r)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, data.accept)
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), data.contentType), data.accept)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"), data.accept)
+		assert.Contains(t, w.Body.String(), data.response, data.accept)
+	}
+}
@@ -1,11 +1,15 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 )
 
-import "github.com/hurstcain/tasks_l2/develop/dev11/internal/model"
+import (
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/cache"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/model"
+)
 
 type PostResponse struct {
 	Result model.Event `json:"result"`
@@ -19,6 +23,14 @@ type GetResponse struct {
 	Result []model.Event `json:"result"`
 }
 
+type RetentionStatsResponse struct {
+	Result cache.RetentionStats `json:"result"`
+}
+
+type TenantStatsResponse struct {
+	Result []cache.TenantStats `json:"result"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
@@ -61,11 +73,58 @@ func SendDeleteResponse(w http.ResponseWriter) error {
 	return nil
 }
 
-func SendGetResponse(w http.ResponseWriter, events []model.Event) error {
+func SendGetResponse(w http.ResponseWriter, r *http.Request, events []model.Event) error {
 	resultResponse := GetResponse{
 		Result: events,
 	}
 
+	w.Header().Set("Vary", "Accept")
+
+	accept := r.Header.Get("Accept")
+	contentType, ok := negotiateContentType(accept)
+	if !ok {
+		return newStatusError(http.StatusNotAcceptable, unacceptableError(accept))
+	}
+
+	var response bytes.Buffer
+	if err := getResponseEncoders[contentType](&response, resultResponse); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	_, err := w.Write(response.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SendRetentionStatsResponse(w http.ResponseWriter, stats cache.RetentionStats) error {
+	resultResponse := RetentionStatsResponse{
+		Result: stats,
+	}
+
+	response, err := json.Marshal(resultResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SendTenantStatsResponse(w http.ResponseWriter, stats []cache.TenantStats) error {
+	resultResponse := TenantStatsResponse{
+		Result: stats,
+	}
+
 	response, err := json.Marshal(resultResponse)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -80,7 +139,7 @@ func SendGetResponse(w http.ResponseWriter, events []model.Event) error {
 	return nil
 }
 
-func SendErrorResponse503(w http.ResponseWriter, err error) error {
+func SendErrorResponse(w http.ResponseWriter, status int, err error) error {
 	errorResponse := ErrorResponse{
 		Error: err.Error(),
 	}
@@ -91,7 +150,26 @@ func SendErrorResponse503(w http.ResponseWriter, err error) error {
 		return err
 	}
 
-	http.Error(w, string(response), http.StatusServiceUnavailable)
+	http.Error(w, string(response), status)
+
+	return nil
+}
+
+// SendGetErrorResponse sends the error of a GetResponse endpoint in the negotiated content type.
+func SendGetErrorResponse(w http.ResponseWriter, contentType string, status int, err error) error {
+	var response bytes.Buffer
+	if encodeErr := getErrorEncoders[contentType](&response, err); encodeErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return encodeErr
+	}
+
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, err = w.Write(response.Bytes())
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -15,36 +17,39 @@ import (
 )
 
 const (
-	ParamEventId      = "event_id"
-	ParamUserId       = "user_id"
-	ParamDate         = "date"
-	ParamEventContent = "event_content"
+	ParamEventId         = "event_id"
+	ParamUserId          = "user_id"
+	ParamDate            = "date"
+	ParamEventContent    = "event_content"
+	ParamIncludeArchived = "include_archived"
 )
 
 type Service struct {
-	server http.Server
-	cache  *cache.Cache
-	logger *logger.Logger
+	server  http.Server
+	tenants *cache.Tenants
+	logger  *logger.Logger
 }
 
-func NewService() *Service {
+func NewService(tenants *cache.Tenants) *Service {
 	addr := config.Ip + ":" + config.Port
 
 	service := &Service{
 		server: http.Server{
 			Addr: addr,
 		},
-		cache:  cache.NewCache(),
-		logger: logger.NewLogger(),
+		tenants: tenants,
+		logger:  logger.NewLogger(),
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/create_event", service.CreateEvent)
-	mux.HandleFunc("/update_event", service.UpdateEvent)
-	mux.HandleFunc("/delete_event", service.DeleteEvent)
-	mux.HandleFunc("/events_for_day", service.GetEventsForDay)
-	mux.HandleFunc("/events_for_week", service.GetEventsForWeek)
-	mux.HandleFunc("/events_for_month", service.GetEventsForMonth)
+	mux.HandleFunc("/create_event", service.handle(Post(Decode(parseBodyToEvent, service.CreateEvent))))
+	mux.HandleFunc("/update_event", service.handle(Post(Decode(parseBodyToEvent, service.UpdateEvent))))
+	mux.HandleFunc("/delete_event", service.handle(Post(Decode(parseEventId, service.DeleteEvent))))
+	mux.HandleFunc("/events_for_day", service.handle(Get(Decode(parseEventsQuery, service.GetEventsForDay))))
+	mux.HandleFunc("/events_for_week", service.handle(Get(Decode(parseEventsQuery, service.GetEventsForWeek))))
+	mux.HandleFunc("/events_for_month", service.handle(Get(Decode(parseEventsQuery, service.GetEventsForMonth))))
+	mux.HandleFunc("/retention_stats", service.handle(Get(service.GetRetentionStats)))
+	mux.HandleFunc("/tenant_stats", service.handle(Get(service.GetTenantStats)))
 
 	service.server.Handler = service.logger.LogRequest(mux)
 
@@ -69,235 +74,162 @@ func (s *Service) Stop() {
 	}
 }
 
-func (s *Service) CreateEvent(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong method")
-		return
+func (s *Service) CreateEvent(w http.ResponseWriter, r *http.Request, event model.Event) error {
+	c, err := s.writableCache(r)
+	if err != nil {
+		return err
 	}
 
-	if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong content-type")
-		return
+	if err := c.CreateEvent(event); err != nil {
+		return businessError(err)
 	}
 
-	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Request is not fulfilled. Can't parse data from body: %s", err.Error())
-		return
-	}
+	return SendPostResponse(w, event)
+}
 
-	event, err := parseBodyToEvent(r.PostForm)
+func (s *Service) UpdateEvent(w http.ResponseWriter, r *http.Request, event model.Event) error {
+	c, err := s.writableCache(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Request is not fulfilled. Error: %s", err.Error())
-		return
+		return err
 	}
 
-	if err := s.cache.CreateEvent(event); err != nil {
-		s.logger.Printf("Business logic error: %s", err.Error())
-		responseErr := SendErrorResponse503(w, err)
-		if responseErr != nil {
-			s.logger.Printf("Error: %s", err.Error())
-		}
-		return
+	if err := c.UpdateEvent(event); err != nil {
+		return businessError(err)
 	}
 
-	err = SendPostResponse(w, event)
-	if err != nil {
-		s.logger.Printf("Error: %s", err.Error())
-	}
+	return SendPostResponse(w, event)
 }
 
-func (s *Service) UpdateEvent(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong method")
-		return
+func (s *Service) DeleteEvent(w http.ResponseWriter, r *http.Request, eventId string) error {
+	c, err := s.writableCache(r)
+	if err != nil {
+		return err
 	}
 
-	if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong content-type")
-		return
+	if err := c.DeleteEvent(eventId); err != nil {
+		return businessError(err)
 	}
 
-	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Request is not fulfilled. Can't parse data from body: %s", err.Error())
-		return
-	}
+	return SendDeleteResponse(w)
+}
 
-	event, err := parseBodyToEvent(r.PostForm)
+func (s *Service) GetEventsForDay(w http.ResponseWriter, r *http.Request, q eventsQuery) error {
+	c, err := s.cache(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Request is not fulfilled. Error: %s", err.Error())
-		return
+		return err
 	}
 
-	if err := s.cache.UpdateEvent(event); err != nil {
-		s.logger.Printf("Business logic error: %s", err.Error())
-		responseErr := SendErrorResponse503(w, err)
-		if responseErr != nil {
-			s.logger.Printf("Error: %s", err.Error())
-		}
-		return
-	}
-
-	err = SendPostResponse(w, event)
+	events, err := c.GetEventsForDay(q.userId, q.date, q.includeArchived)
 	if err != nil {
-		s.logger.Printf("Error: %s", err.Error())
+		return getError(w, r, businessError(err))
 	}
-}
 
-func (s *Service) DeleteEvent(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong method")
-		return
-	}
-
-	if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong content-type")
-		return
-	}
+	return SendGetResponse(w, r, events)
+}
 
-	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Request is not fulfilled. Can't parse data from body: %s", err.Error())
-		return
+func (s *Service) GetEventsForWeek(w http.ResponseWriter, r *http.Request, q eventsQuery) error {
+	c, err := s.cache(r)
+	if err != nil {
+		return err
 	}
 
-	eventId := r.PostForm.Get(ParamEventId)
-	if err := model.CheckEventId(eventId); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Invalid data. Error: %s", err.Error())
-		return
+	events, err := c.GetEventsForWeek(q.userId, q.date, q.includeArchived)
+	if err != nil {
+		return getError(w, r, businessError(err))
 	}
 
-	if err := s.cache.DeleteEvent(eventId); err != nil {
-		s.logger.Printf("Business logic error: %s", err.Error())
-		responseErr := SendErrorResponse503(w, err)
-		if responseErr != nil {
-			s.logger.Printf("Error: %s", err.Error())
-		}
-		return
-	}
+	return SendGetResponse(w, r, events)
+}
 
-	err := SendDeleteResponse(w)
+func (s *Service) GetEventsForMonth(w http.ResponseWriter, r *http.Request, q eventsQuery) error {
+	c, err := s.cache(r)
 	if err != nil {
-		s.logger.Printf("Error: %s", err.Error())
+		return err
 	}
-}
 
-func (s *Service) GetEventsForDay(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong method")
-		return
+	events, err := c.GetEventsForMonth(q.userId, q.date, q.includeArchived)
+	if err != nil {
+		return getError(w, r, businessError(err))
 	}
 
-	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Request is not fulfilled. Can't parse data from body: %s", err.Error())
-		return
-	}
+	return SendGetResponse(w, r, events)
+}
 
-	userId, date, err := parseQueryString(r.Form)
+func (s *Service) GetRetentionStats(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.cache(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Invalid data. Error: %s", err.Error())
-		return
+		return err
 	}
 
-	events, err := s.cache.GetEventsForDay(userId, date)
-	if err != nil {
-		s.logger.Printf("Business logic error: %s", err.Error())
-		responseErr := SendErrorResponse503(w, err)
-		if responseErr != nil {
-			s.logger.Printf("Error: %s", err.Error())
-		}
-		return
-	}
+	return SendRetentionStatsResponse(w, c.RetentionStats())
+}
 
-	err = SendGetResponse(w, events)
+// GetTenantStats returns statistics of the tenant the request belongs to only,
+// so tenants can't see each other.
+func (s *Service) GetTenantStats(w http.ResponseWriter, r *http.Request) error {
+	tenant, err := requestTenant(r)
 	if err != nil {
-		s.logger.Printf("Error: %s", err.Error())
+		return err
 	}
+
+	return SendTenantStatsResponse(w, []cache.TenantStats{s.tenants.Stats(tenant)})
 }
 
-func (s *Service) GetEventsForWeek(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong method")
-		return
+// cache returns the events cache of the tenant the request belongs to for reading.
+// It doesn't create the tenant.
+func (s *Service) cache(r *http.Request) (*cache.Cache, error) {
+	tenant, err := requestTenant(r)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Request is not fulfilled. Can't parse data from body: %s", err.Error())
-		return
-	}
+	return s.tenants.Get(tenant), nil
+}
 
-	userId, date, err := parseQueryString(r.Form)
+// writableCache returns the events cache of the tenant the request belongs to,
+// creating the tenant if needed.
+func (s *Service) writableCache(r *http.Request) (*cache.Cache, error) {
+	tenant, err := requestTenant(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Invalid data. Error: %s", err.Error())
-		return
+		return nil, err
 	}
 
-	events, err := s.cache.GetEventsForWeek(userId, date)
+	c, err := s.tenants.GetOrCreate(tenant)
 	if err != nil {
-		s.logger.Printf("Business logic error: %s", err.Error())
-		responseErr := SendErrorResponse503(w, err)
-		if responseErr != nil {
-			s.logger.Printf("Error: %s", err.Error())
-		}
-		return
+		return nil, businessError(err)
 	}
 
-	err = SendGetResponse(w, events)
-	if err != nil {
-		s.logger.Printf("Error: %s", err.Error())
-	}
+	return c, nil
 }
 
-func (s *Service) GetEventsForMonth(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.Println("Request is not fulfilled. Wrong method")
-		return
+// requestTenant returns the tenant the request belongs to.
+func requestTenant(r *http.Request) (string, error) {
+	tenant := r.Header.Get(config.TenantHeader)
+	if tenant == "" {
+		return config.DefaultTenant, nil
 	}
 
-	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Request is not fulfilled. Can't parse data from body: %s", err.Error())
-		return
+	if err := cache.ValidateTenantId(tenant); err != nil {
+		return "", newRequestError(http.StatusBadRequest, err)
 	}
 
-	userId, date, err := parseQueryString(r.Form)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.Printf("Invalid data. Error: %s", err.Error())
-		return
-	}
+	return tenant, nil
+}
 
-	events, err := s.cache.GetEventsForMonth(userId, date)
-	if err != nil {
-		s.logger.Printf("Business logic error: %s", err.Error())
-		responseErr := SendErrorResponse503(w, err)
-		if responseErr != nil {
-			s.logger.Printf("Error: %s", err.Error())
-		}
-		return
-	}
+// businessError is returned when the cache refuses to fulfill the request.
+func businessError(err error) *StatusError {
+	return newStatusError(http.StatusServiceUnavailable, err)
+}
 
-	err = SendGetResponse(w, events)
-	if err != nil {
-		s.logger.Printf("Error: %s", err.Error())
+// getError makes err of a GetResponse endpoint, e.g. empty result, to be sent
+// in the content type negotiated by the Accept header.
+func getError(w http.ResponseWriter, r *http.Request, err *StatusError) *StatusError {
+	w.Header().Set("Vary", "Accept")
+	if contentType, ok := negotiateContentType(r.Header.Get("Accept")); ok {
+		err.ContentType = contentType
 	}
+
+	return err
 }
 
 func parseBodyToEvent(s url.Values) (model.Event, error) {
@@ -310,14 +242,53 @@ func parseBodyToEvent(s url.Values) (model.Event, error) {
 	return event, err
 }
 
-func parseQueryString(s url.Values) (string, time.Time, error) {
+type eventsQuery struct {
+	userId          string
+	date            time.Time
+	includeArchived bool
+}
+
+func parseEventId(s url.Values) (string, error) {
+	eventId := s.Get(ParamEventId)
+
+	err := model.CheckEventId(eventId)
+	return eventId, err
+}
+
+func parseEventsQuery(s url.Values) (eventsQuery, error) {
 	userId := s.Get(ParamUserId)
 	date := s.Get(ParamDate)
 
 	if err := model.CheckUserId(userId); err != nil {
-		return "", time.Time{}, err
+		return eventsQuery{}, err
 	}
 
 	t, err := model.CheckDate(date)
-	return userId, t, err
+	if err != nil {
+		return eventsQuery{}, err
+	}
+
+	includeArchived, err := parseIncludeArchived(s.Get(ParamIncludeArchived))
+	if err != nil {
+		return eventsQuery{}, err
+	}
+
+	return eventsQuery{
+		userId:          userId,
+		date:            t,
+		includeArchived: includeArchived,
+	}, nil
+}
+
+func parseIncludeArchived(s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+
+	includeArchived, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("wrong %s value: %s", ParamIncludeArchived, s)
+	}
+
+	return includeArchived, nil
 }
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/cache"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/config"
+	"github.com/hurstcain/tasks_l2/develop/dev11/internal/grpcservice"
 	"github.com/hurstcain/tasks_l2/develop/dev11/internal/service"
 	"log"
 	"os"
@@ -8,7 +11,14 @@ import (
 )
 
 func main() {
-	s := service.NewService()
+	tenantConfigs := make(map[string]cache.TenantConfig, len(config.Tenants))
+	for tenant, settings := range config.Tenants {
+		tenantConfigs[tenant] = newTenantConfig(settings)
+	}
+	tenants := cache.NewTenants(newTenantConfig(config.DefaultTenantSettings), tenantConfigs, config.MaxTenants)
+
+	s := service.NewService(tenants)
+	gs := grpcservice.NewService(tenants)
 
 	chExit := make(chan os.Signal, 1)
 	signal.Notify(chExit, os.Interrupt)
@@ -17,9 +27,24 @@ func main() {
 		select {
 		case <-chExit:
 			log.Println("Closing server...")
+			gs.Stop()
 			s.Stop()
+			tenants.StopCompactors()
 		}
 	}()
 
+	go gs.Run()
 	s.Run()
 }
+
+func newTenantConfig(settings config.Tenant) cache.TenantConfig {
+	return cache.TenantConfig{
+		MaxEvents: settings.MaxEvents,
+		Retention: cache.RetentionPolicy{
+			Months:        settings.RetentionMonths,
+			Purge:         settings.RetentionPurge,
+			ArchiveMonths: settings.ArchiveRetentionMonths,
+			Interval:      config.CompactionInterval,
+		},
+	}
+}